@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/JackalLabs/jindexer/database"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// chainDatabase returns the database scoped to the network selected with the chain_id query
+// parameter, falling back to the JINDEXER_CHAIN_ID environment variable. Without either the
+// endpoints see every indexed network.
+func chainDatabase(c *gin.Context, d *database.Database) *database.Database {
+	chainID := c.Query("chain_id")
+	if chainID == "" {
+		chainID = os.Getenv("JINDEXER_CHAIN_ID")
+	}
+	if chainID == "" {
+		return d
+	}
+	return d.ForChain(chainID)
+}
+
+// RegisterChainsEndpoint adds the /chains endpoint to the router, listing the indexed networks
+// that can be passed as chain_id to the other endpoints
+func RegisterChainsEndpoint(r *gin.Engine, d *database.Database) {
+	r.GET("/chains", func(c *gin.Context) {
+		chainIDs, err := d.ListChainIDs()
+		if err != nil {
+			log.Err(err).Msg("failed to query chains")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"chains":  chainIDs,
+			"default": os.Getenv("JINDEXER_CHAIN_ID"),
+		})
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/JackalLabs/jindexer/database"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// RegisterDisputesEndpoint adds the /disputes endpoint to the router, listing the attestation and
+// report forms raised over a merkle or against a prover
+func RegisterDisputesEndpoint(r *gin.Engine, d *database.Database) {
+	r.GET("/disputes", func(c *gin.Context) {
+		db := chainDatabase(c, d)
+
+		merkle := c.Query("merkle")
+		prover := c.Query("prover")
+		if merkle == "" && prover == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "merkle or prover parameter is required"})
+			return
+		}
+
+		// Parse optional start and end dates, default to 30 days from current time
+		now := time.Now()
+		endTime := now
+		startTime := now.AddDate(0, 0, -30)
+
+		if startDateStr := c.Query("start_date"); startDateStr != "" {
+			parsedStart, err := time.Parse(time.RFC3339, startDateStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_date format, use RFC3339 (e.g., 2006-01-02T15:04:05Z07:00)"})
+				return
+			}
+			startTime = parsedStart
+		}
+
+		if endDateStr := c.Query("end_date"); endDateStr != "" {
+			parsedEnd, err := time.Parse(time.RFC3339, endDateStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_date format, use RFC3339 (e.g., 2006-01-02T15:04:05Z07:00)"})
+				return
+			}
+			endTime = parsedEnd
+		}
+
+		disputes, err := db.ListDisputes(merkle, prover, startTime, endTime)
+		if err != nil {
+			log.Err(err).Msg("failed to query disputes")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+			return
+		}
+
+		response := gin.H{
+			"merkle":     merkle,
+			"prover":     prover,
+			"start_date": startTime,
+			"end_date":   endTime,
+			"disputes":   disputes,
+			"count":      len(disputes),
+		}
+
+		if c.Query("events") == "true" {
+			events := make(map[uint]interface{}, len(disputes))
+			for _, dispute := range disputes {
+				disputeEvents, err := db.ListDisputeEvents(dispute.ID)
+				if err != nil {
+					log.Err(err).Uint("dispute", dispute.ID).Msg("failed to query dispute events")
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+					return
+				}
+				events[dispute.ID] = disputeEvents
+			}
+			response["events"] = events
+		}
+
+		c.JSON(http.StatusOK, response)
+	})
+}
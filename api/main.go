@@ -1,14 +1,17 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/JackalLabs/jindexer/database"
+	"github.com/JackalLabs/jindexer/types"
 	"github.com/JackalLabs/jindexer/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -34,8 +37,22 @@ func main() {
 	// Register report endpoint for 12-hour window analysis
 	RegisterReportEndpoint(r, d)
 
+	// Register owner endpoint for storage plans next to file proof health
+	RegisterOwnerEndpoint(r, d)
+
+	// Register disputes endpoint for attestation and report forms
+	RegisterDisputesEndpoint(r, d)
+
+	// Register message stats endpoint showing on-chain activity per message type and day
+	RegisterStatsEndpoint(r, d)
+
+	// Register chains endpoint listing the indexed networks, every endpoint takes ?chain_id= to pick one
+	RegisterChainsEndpoint(r, d)
+
 	// Query endpoint for proofs by merkle and date range
 	r.GET("/query", func(c *gin.Context) {
+		db := chainDatabase(c, d)
+
 		merkle := c.Query("merkle")
 		if merkle == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "merkle parameter is required"})
@@ -65,25 +82,75 @@ func main() {
 			endTime = parsedEnd
 		}
 
+		// Optional owner and start narrow the query down to a single file deal
+		owner := c.Query("owner")
+		var start int64
+		if startStr := c.Query("start"); startStr != "" {
+			parsedStart, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || parsedStart < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start parameter, must be a block height"})
+				return
+			}
+			start = parsedStart
+		}
+
+		// Proofs from failed transactions and proofs rejected by the chain are hidden unless explicitly requested
+		includeFailed := c.Query("include_failed") == "true"
+
 		// Get proofs from database
-		proofs, err := d.ListProofsByMerkleAndTimeRange(merkle, startTime, endTime)
+		proofs, err := db.ListProofsByMerkleAndTimeRange(merkle, owner, start, startTime, endTime, includeFailed)
 		if err != nil {
 			log.Err(err).Msg("failed to query proofs")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		// Files stored under the merkle, including whether they have been deleted or expired
+		files, err := db.ListFilesByMerkle(merkle)
+		if err != nil {
+			log.Err(err).Msg("failed to query files")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+			return
+		}
+		if owner != "" || start != 0 {
+			dealFiles := make([]types.File, 0, len(files))
+			for _, file := range files {
+				if (owner == "" || file.Owner == owner) && (start == 0 || file.Start == start) {
+					dealFiles = append(dealFiles, file)
+				}
+			}
+			files = dealFiles
+		}
+
+		inactiveMerkles, err := db.GetInactiveMerkles(owner, start, merkle)
+		if err != nil {
+			log.Err(err).Msg("failed to query merkle activity")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+			return
+		}
+
+		response := gin.H{
 			"merkle":     merkle,
+			"owner":      owner,
+			"start":      start,
 			"start_date": startTime,
 			"end_date":   endTime,
 			"proofs":     proofs,
 			"count":      len(proofs),
-		})
+			"files":      files,
+			"active":     len(inactiveMerkles) == 0,
+		}
+		if len(inactiveMerkles) > 0 {
+			response["inactive_since"] = inactiveMerkles[0].InactiveSince
+		}
+
+		c.JSON(http.StatusOK, response)
 	})
 
 	// Recent proofs endpoint - lists most recent proofs ordered by block date with a limit
 	r.GET("/recent", func(c *gin.Context) {
+		db := chainDatabase(c, d)
+
 		// Parse limit parameter, default to 100 if not provided
 		limitStr := c.DefaultQuery("limit", "100")
 		limit, err := strconv.Atoi(limitStr)
@@ -93,7 +160,7 @@ func main() {
 		}
 
 		// Get recent proofs from database
-		proofs, err := d.ListRecentProofs(limit)
+		proofs, err := db.ListRecentProofs(limit)
 		if err != nil {
 			log.Err(err).Msg("failed to query recent proofs")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
@@ -109,6 +176,8 @@ func main() {
 
 	// Proofs endpoint - lists proofs ordered by ID (most recent first) with a limit
 	r.GET("/proofs", func(c *gin.Context) {
+		db := chainDatabase(c, d)
+
 		// Parse limit parameter, default to 100 if not provided
 		limitStr := c.DefaultQuery("limit", "100")
 		limit, err := strconv.Atoi(limitStr)
@@ -118,7 +187,7 @@ func main() {
 		}
 
 		// Get proofs from database ordered by ID
-		proofs, err := d.ListProofsByID(limit)
+		proofs, err := db.ListProofsByID(limit)
 		if err != nil {
 			log.Err(err).Msg("failed to query proofs")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
@@ -132,14 +201,107 @@ func main() {
 		})
 	})
 
-	// Provider endpoint - returns the IP/domain for a given Jackal address
+	// Invalid proofs endpoint - counts per prover the proofs that failed offline merkle verification.
+	// With a prover only that prover is counted, along with its most recent invalid proofs.
+	r.GET("/proofs/invalid", func(c *gin.Context) {
+		db := chainDatabase(c, d)
+
+		prover := c.Query("prover")
+		if prover == "" {
+			counts, err := db.GetInvalidProofCounts()
+			if err != nil {
+				log.Err(err).Msg("failed to query invalid proof counts")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"provers": counts,
+				"count":   len(counts),
+			})
+			return
+		}
+
+		limitStr := c.DefaultQuery("limit", "100")
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter, must be a positive integer"})
+			return
+		}
+
+		counts, err := db.GetInvalidProofCounts(prover)
+		if err != nil {
+			log.Err(err).Str("prover", prover).Msg("failed to query invalid proof counts")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+			return
+		}
+
+		proofs, err := db.ListInvalidProofs(prover, limit)
+		if err != nil {
+			log.Err(err).Str("prover", prover).Msg("failed to query invalid proofs")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+			return
+		}
+
+		response := gin.H{
+			"prover":   prover,
+			"verified": int64(0),
+			"invalid":  int64(0),
+			"proofs":   proofs,
+		}
+		if len(counts) > 0 {
+			response["verified"] = counts[0].Verified
+			response["invalid"] = counts[0].Invalid
+		}
+
+		c.JSON(http.StatusOK, response)
+	})
+
+	// Provider endpoint - returns the IP/domain for a given Jackal address.
+	// Indexed providers are answered from the database, anything else falls back to the Jackal API,
+	// as do providers whose IP has not been indexed (first seen after their init).
 	r.GET("/provider/:address", func(c *gin.Context) {
+		db := chainDatabase(c, d)
+
 		address := c.Param("address")
 		if address == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "address parameter is required"})
 			return
 		}
 
+		provider, err := db.GetProvider(address)
+		if err == nil {
+			ip := provider.IP
+			if ip == "" {
+				ip, err = providerCache.GetProviderIP(address)
+				if err != nil {
+					log.Err(err).Str("address", address).Msg("failed to get provider IP")
+				}
+			}
+
+			response := gin.H{
+				"address":  address,
+				"ip":       ip,
+				"provider": provider,
+			}
+
+			if c.Query("history") == "true" {
+				history, err := db.ListProviderEvents(address)
+				if err != nil {
+					log.Err(err).Str("address", address).Msg("failed to query provider history")
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+					return
+				}
+				response["history"] = history
+			}
+
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Err(err).Str("address", address).Msg("failed to query provider")
+		}
+
 		ip, err := providerCache.GetProviderIP(address)
 		if err != nil {
 			log.Err(err).Str("address", address).Msg("failed to get provider IP")
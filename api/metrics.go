@@ -19,71 +19,116 @@ const (
 )
 
 var (
-	// Aggregate metrics - these don't have high cardinality labels
+	// Aggregate metrics - these don't have high cardinality labels, only the chain_id of the network
 
-	// TotalMerklesTracked is the total number of unique merkles in the database
-	TotalMerklesTracked = prometheus.NewGauge(
+	// TotalMerklesTracked is the total number of unique active merkles in the database
+	TotalMerklesTracked = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "jindexer_merkles_total",
-			Help: "Total number of unique merkles being tracked",
+			Help: "Total number of unique active merkles being tracked",
 		},
+		[]string{"chain_id"},
 	)
 
 	// TotalProofsIndexed is the total number of proofs in the database
-	TotalProofsIndexed = prometheus.NewGauge(
+	TotalProofsIndexed = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "jindexer_proofs_total",
 			Help: "Total number of proofs indexed in the database",
 		},
+		[]string{"chain_id"},
+	)
+
+	// TotalFilesTracked is the total number of files posted on chain that have been indexed
+	TotalFilesTracked = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "jindexer_files_total",
+			Help: "Total number of files indexed from MsgPostFile",
+		},
+		[]string{"chain_id"},
+	)
+
+	// MerklesUnproven is the count of posted merkles that have never had a successful proof
+	MerklesUnproven = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "jindexer_merkles_unproven",
+			Help: "Number of posted merkles that have never been proven",
+		},
+		[]string{"chain_id"},
+	)
+
+	// MerklesInactive is the count of merkles whose files have all been deleted or expired
+	MerklesInactive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "jindexer_merkles_inactive",
+			Help: "Number of merkles whose files have all been deleted or expired",
+		},
+		[]string{"chain_id"},
 	)
 
 	// MerklesHealthy is the count of merkles with proofs within the 12h window
-	MerklesHealthy = prometheus.NewGauge(
+	MerklesHealthy = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "jindexer_merkles_healthy",
 			Help: "Number of merkles with proofs within the 12-hour window",
 		},
+		[]string{"chain_id"},
 	)
 
 	// MerklesMissed is the count of merkles that have missed the 12h proof window
-	MerklesMissed = prometheus.NewGauge(
+	MerklesMissed = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "jindexer_merkles_missed",
 			Help: "Number of merkles that have missed the 12-hour proof window",
 		},
+		[]string{"chain_id"},
 	)
 
 	// MerklesCritical is the count of merkles that have missed the 24h window
-	MerklesCritical = prometheus.NewGauge(
+	MerklesCritical = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "jindexer_merkles_critical",
 			Help: "Number of merkles that have missed the 24-hour proof window (critical)",
 		},
+		[]string{"chain_id"},
 	)
 
 	// ProofAgeHistogram tracks the distribution of proof ages
-	ProofAgeHistogram = prometheus.NewHistogram(
+	ProofAgeHistogram = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "jindexer_proof_age_seconds",
 			Help:    "Histogram of proof ages in seconds",
 			Buckets: []float64{3600, 7200, 10800, 14400, 21600, 43200, 64800, 86400, 172800}, // 1h, 2h, 3h, 4h, 6h, 12h, 18h, 24h, 48h
 		},
+		[]string{"chain_id"},
 	)
 
 	// OldestProofAge tracks the age of the oldest proof (worst case)
-	OldestProofAge = prometheus.NewGauge(
+	OldestProofAge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "jindexer_oldest_proof_age_seconds",
 			Help: "Age of the oldest proof in seconds (worst case merkle)",
 		},
+		[]string{"chain_id"},
+	)
+
+	// InvalidProofs tracks per prover the proofs that failed offline merkle verification.
+	// Only provers with at least one invalid proof get a series.
+	InvalidProofs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "jindexer_invalid_proofs",
+			Help: "Number of proofs per prover that failed offline merkle verification",
+		},
+		[]string{"chain_id", "prover"},
 	)
 
 	// NewestProofAge tracks the age of the newest proof
-	NewestProofAge = prometheus.NewGauge(
+	NewestProofAge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "jindexer_newest_proof_age_seconds",
 			Help: "Age of the most recent proof in seconds",
 		},
+		[]string{"chain_id"},
 	)
 )
 
@@ -91,12 +136,26 @@ func init() {
 	// Register aggregate metrics with Prometheus
 	prometheus.MustRegister(TotalMerklesTracked)
 	prometheus.MustRegister(TotalProofsIndexed)
+	prometheus.MustRegister(TotalFilesTracked)
+	prometheus.MustRegister(MerklesUnproven)
+	prometheus.MustRegister(MerklesInactive)
 	prometheus.MustRegister(MerklesHealthy)
 	prometheus.MustRegister(MerklesMissed)
 	prometheus.MustRegister(MerklesCritical)
 	prometheus.MustRegister(ProofAgeHistogram)
 	prometheus.MustRegister(OldestProofAge)
 	prometheus.MustRegister(NewestProofAge)
+	prometheus.MustRegister(InvalidProofs)
+}
+
+// proofHealth classifies the age of a merkle's last proof in seconds as healthy, missed or critical
+func proofHealth(age int64) string {
+	if age <= proofWindowSeconds {
+		return "healthy"
+	} else if age <= criticalWindowSeconds {
+		return "missed"
+	}
+	return "critical"
 }
 
 // RegisterMetricsEndpoint adds the /metrics endpoint to the router
@@ -127,8 +186,21 @@ func InitializeMetricsFromDatabase(d *database.Database) {
 	log.Info().Msg("Prometheus metrics refresh goroutine started (30s interval)")
 }
 
-// refreshMetricsFromDatabase queries the database and computes aggregate metrics
+// refreshMetricsFromDatabase computes the aggregate metrics of every indexed network
 func refreshMetricsFromDatabase(d *database.Database) {
+	chainIDs, err := d.ListChainIDs()
+	if err != nil {
+		log.Err(err).Msg("failed to list indexed chains")
+		return
+	}
+
+	for _, chainID := range chainIDs {
+		refreshChainMetrics(d.ForChain(chainID), chainID)
+	}
+}
+
+// refreshChainMetrics queries the database and computes the aggregate metrics of a single network
+func refreshChainMetrics(d *database.Database, chainID string) {
 	// Use SQL aggregates to get the latest proof time per merkle instead of
 	// loading individual rows into Go memory.
 	merkleProofs, err := d.GetMerkleLastProofTimes()
@@ -143,16 +215,69 @@ func refreshMetricsFromDatabase(d *database.Database) {
 		return
 	}
 
+	totalFiles, err := d.GetTotalFileCount()
+	if err != nil {
+		log.Err(err).Msg("failed to get total file count")
+		return
+	}
+
+	// Files that were posted but never proven are aged from the time they were posted,
+	// so a file nobody ever proved ends up missed and then critical like any other merkle.
+	unprovenMerkles, err := d.GetUnprovenMerklePostedTimes()
+	if err != nil {
+		log.Err(err).Msg("failed to get unproven merkles")
+		return
+	}
+
+	// Merkles whose files have all been deleted or expired no longer need proofs
+	inactiveMerkles, err := d.GetInactiveMerkles("", 0)
+	if err != nil {
+		log.Err(err).Msg("failed to get inactive merkles")
+		return
+	}
+	inactive := make(map[string]bool, len(inactiveMerkles))
+	for _, im := range inactiveMerkles {
+		inactive[im.Merkle] = true
+	}
+
+	invalidProofs, err := d.GetInvalidProofCounts()
+	if err != nil {
+		log.Err(err).Msg("failed to get invalid proof counts")
+		return
+	}
+
 	now := time.Now().Unix()
 
-	totalMerkles := len(merkleProofs)
-	var healthy, missed, critical int
+	var totalMerkles, unproven, healthy, missed, critical int
 	var oldestAge, newestAge int64 = 0, math.MaxInt64
 
+	for _, um := range unprovenMerkles {
+		if inactive[um.Merkle] {
+			continue
+		}
+		totalMerkles++
+		unproven++
+
+		age := now - um.PostedTime.Unix()
+
+		if age <= proofWindowSeconds {
+			healthy++
+		} else if age <= criticalWindowSeconds {
+			missed++
+		} else {
+			critical++
+		}
+	}
+
 	for _, mp := range merkleProofs {
+		if inactive[mp.Merkle] {
+			continue
+		}
+		totalMerkles++
+
 		age := now - mp.LastProofTime.Unix()
 
-		ProofAgeHistogram.Observe(float64(age))
+		ProofAgeHistogram.WithLabelValues(chainID).Observe(float64(age))
 
 		if age > oldestAge {
 			oldestAge = age
@@ -170,22 +295,34 @@ func refreshMetricsFromDatabase(d *database.Database) {
 		}
 	}
 
-	if totalMerkles == 0 {
+	if newestAge == math.MaxInt64 {
 		newestAge = 0
 	}
 
-	TotalMerklesTracked.Set(float64(totalMerkles))
-	TotalProofsIndexed.Set(float64(totalProofs))
-	MerklesHealthy.Set(float64(healthy))
-	MerklesMissed.Set(float64(missed))
-	MerklesCritical.Set(float64(critical))
-	OldestProofAge.Set(float64(oldestAge))
-	NewestProofAge.Set(float64(newestAge))
+	TotalMerklesTracked.WithLabelValues(chainID).Set(float64(totalMerkles))
+	TotalProofsIndexed.WithLabelValues(chainID).Set(float64(totalProofs))
+	TotalFilesTracked.WithLabelValues(chainID).Set(float64(totalFiles))
+	MerklesUnproven.WithLabelValues(chainID).Set(float64(unproven))
+	MerklesInactive.WithLabelValues(chainID).Set(float64(len(inactiveMerkles)))
+	MerklesHealthy.WithLabelValues(chainID).Set(float64(healthy))
+	MerklesMissed.WithLabelValues(chainID).Set(float64(missed))
+	MerklesCritical.WithLabelValues(chainID).Set(float64(critical))
+	OldestProofAge.WithLabelValues(chainID).Set(float64(oldestAge))
+	NewestProofAge.WithLabelValues(chainID).Set(float64(newestAge))
+
+	InvalidProofs.DeletePartialMatch(prometheus.Labels{"chain_id": chainID})
+	for _, ip := range invalidProofs {
+		InvalidProofs.WithLabelValues(chainID, ip.Prover).Set(float64(ip.Invalid))
+	}
 
 	log.Debug().
+		Str("chain_id", chainID).
 		Int("total_merkles", totalMerkles).
+		Int("unproven", unproven).
+		Int("inactive", len(inactiveMerkles)).
 		Int("healthy", healthy).
 		Int("missed", missed).
 		Int("critical", critical).
+		Int("provers_with_invalid_proofs", len(invalidProofs)).
 		Msg("refreshed proof metrics")
 }
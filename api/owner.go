@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/JackalLabs/jindexer/database"
+	"github.com/JackalLabs/jindexer/types"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// OwnerFile is a file of an owner along with its proof health
+type OwnerFile struct {
+	File          types.File `json:"file"`
+	LastProofTime *time.Time `json:"last_proof_time"`
+	// Status is healthy, missed or critical based on the age of the last proof (or of the file
+	// if it was never proven), or inactive once the file has been deleted or has expired
+	Status string `json:"status"`
+}
+
+// OwnerResponse represents the response body for the /owner/:address endpoint
+type OwnerResponse struct {
+	Owner      string                  `json:"owner"`
+	Plan       *types.StoragePlan      `json:"plan"`
+	PlanActive bool                    `json:"plan_active"`
+	Purchases  []types.StoragePurchase `json:"purchases"`
+	Files      []OwnerFile             `json:"files"`
+}
+
+// RegisterOwnerEndpoint adds the /owner/:address endpoint to the router, putting an owner's
+// storage plan next to the proof health of their files
+func RegisterOwnerEndpoint(r *gin.Engine, d *database.Database) {
+	r.GET("/owner/:address", func(c *gin.Context) {
+		address := c.Param("address")
+		if address == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "address parameter is required"})
+			return
+		}
+
+		response, err := ownerStatus(chainDatabase(c, d), address)
+		if err != nil {
+			log.Err(err).Str("address", address).Msg("failed to query owner")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+	})
+}
+
+// ownerStatus collects the storage plan, purchase history and file health of an owner
+func ownerStatus(d *database.Database, owner string) (*OwnerResponse, error) {
+	response := OwnerResponse{Owner: owner}
+
+	plan, err := d.GetStoragePlan(owner)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if plan != nil {
+		response.Plan = plan
+		response.PlanActive = plan.End.After(time.Now())
+	}
+
+	response.Purchases, err = d.ListStoragePurchases(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := d.ListFilesByOwner(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	merkles := make([]string, 0, len(files))
+	for _, f := range files {
+		merkles = append(merkles, f.Merkle)
+	}
+	if len(merkles) == 0 {
+		response.Files = []OwnerFile{}
+		return &response, nil
+	}
+
+	lastProofs, err := d.GetMerkleLastProofTimes(merkles...)
+	if err != nil {
+		return nil, err
+	}
+	lastProofTimes := make(map[string]time.Time, len(lastProofs))
+	for _, lp := range lastProofs {
+		lastProofTimes[lp.Merkle] = lp.LastProofTime
+	}
+
+	inactiveMerkles, err := d.GetInactiveMerkles(owner, 0, merkles...)
+	if err != nil {
+		return nil, err
+	}
+	inactive := make(map[string]bool, len(inactiveMerkles))
+	for _, im := range inactiveMerkles {
+		inactive[im.Merkle] = true
+	}
+
+	now := time.Now().Unix()
+	for _, f := range files {
+		file := OwnerFile{File: f}
+
+		reference := f.Block.Time
+		if lastProof, ok := lastProofTimes[f.Merkle]; ok {
+			file.LastProofTime = &lastProof
+			reference = lastProof
+		}
+
+		if f.EndedHeight > 0 || inactive[f.Merkle] {
+			file.Status = "inactive"
+		} else {
+			file.Status = proofHealth(now - reference.Unix())
+		}
+
+		response.Files = append(response.Files, file)
+	}
+
+	return &response, nil
+}
@@ -5,24 +5,34 @@ import (
 	"time"
 
 	"github.com/JackalLabs/jindexer/database"
+	"github.com/JackalLabs/jindexer/types"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
 
-// ReportRequest represents the request body for the /report endpoint
+// ReportRequest represents the request body for the /report endpoint.
+// Owner and Start optionally restrict the proofs counted to a single owner's file deals.
 type ReportRequest struct {
 	Merkles   []string `json:"merkles" binding:"required,min=1"`
 	StartTime string   `json:"start_time" binding:"required"`
 	EndTime   string   `json:"end_time" binding:"required"`
+	Owner     string   `json:"owner"`
+	Start     int64    `json:"start"`
 }
 
-// ProofWindow represents a 12-hour window with proof status
+// ProofWindow represents a 12-hour window with proof status.
+// Merkles whose files were deleted or expired before the window ended and that have no proof
+// in it are listed as inactive rather than missed.
+// Disputes lists the attestation and report forms raised over missed merkles during or right after
+// the window, showing whether a miss was vouched for or ended in a report.
 type ProofWindow struct {
-	Start         time.Time `json:"start"`
-	End           time.Time `json:"end"`
-	AllProven     bool      `json:"all_proven"`
-	ProvenMerkles []string  `json:"proven_merkles"`
-	MissedMerkles []string  `json:"missed_merkles"`
+	Start           time.Time       `json:"start"`
+	End             time.Time       `json:"end"`
+	AllProven       bool            `json:"all_proven"`
+	ProvenMerkles   []string        `json:"proven_merkles"`
+	MissedMerkles   []string        `json:"missed_merkles"`
+	InactiveMerkles []string        `json:"inactive_merkles"`
+	Disputes        []types.Dispute `json:"disputes"`
 }
 
 // ReportSummary contains aggregate statistics
@@ -35,6 +45,8 @@ type ReportSummary struct {
 // ReportResponse represents the response body for the /report endpoint
 type ReportResponse struct {
 	Merkles []string      `json:"merkles"`
+	Owner   string        `json:"owner,omitempty"`
+	Start   int64         `json:"start,omitempty"`
 	Windows []ProofWindow `json:"windows"`
 	Summary ReportSummary `json:"summary"`
 }
@@ -69,7 +81,7 @@ func RegisterReportEndpoint(r *gin.Engine, d *database.Database) {
 		}
 
 		// Generate report
-		response, err := generateReport(d, req.Merkles, startTime, endTime)
+		response, err := generateReport(chainDatabase(c, d), req.Merkles, req.Owner, req.Start, startTime, endTime)
 		if err != nil {
 			log.Err(err).Msg("failed to generate report")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate report"})
@@ -81,7 +93,7 @@ func RegisterReportEndpoint(r *gin.Engine, d *database.Database) {
 }
 
 // generateReport creates the proof report by analyzing 12-hour windows
-func generateReport(d *database.Database, merkles []string, startTime, endTime time.Time) (*ReportResponse, error) {
+func generateReport(d *database.Database, merkles []string, owner string, start int64, startTime, endTime time.Time) (*ReportResponse, error) {
 	// Build merkle proof map: merkle -> list of proof times
 	merkleProofTimes := make(map[string][]time.Time)
 	for _, merkle := range merkles {
@@ -90,7 +102,7 @@ func generateReport(d *database.Database, merkles []string, startTime, endTime t
 
 	// Query proofs for each merkle
 	for _, merkle := range merkles {
-		proofs, err := d.ListProofsByMerkleAndTimeRange(merkle, startTime, endTime)
+		proofs, err := d.ListProofsByMerkleAndTimeRange(merkle, owner, start, startTime, endTime, false)
 		if err != nil {
 			return nil, err
 		}
@@ -99,6 +111,31 @@ func generateReport(d *database.Database, merkles []string, startTime, endTime t
 		}
 	}
 
+	// Merkle -> time its files stopped needing proofs
+	inactiveMerkles, err := d.GetInactiveMerkles(owner, start, merkles...)
+	if err != nil {
+		return nil, err
+	}
+	inactiveSince := make(map[string]time.Time, len(inactiveMerkles))
+	for _, im := range inactiveMerkles {
+		inactiveSince[im.Merkle] = im.InactiveSince
+	}
+
+	// Disputes raised over the merkles, including those opened just after the last window
+	disputes, err := d.ListDisputesForMerkles(merkles, startTime, endTime.Add(windowDuration))
+	if err != nil {
+		return nil, err
+	}
+	if owner != "" || start != 0 {
+		dealDisputes := disputes[:0]
+		for _, dispute := range disputes {
+			if (owner == "" || dispute.Owner == owner) && (start == 0 || dispute.Start == start) {
+				dealDisputes = append(dealDisputes, dispute)
+			}
+		}
+		disputes = dealDisputes
+	}
+
 	// Generate windows
 	var windows []ProofWindow
 	windowStart := startTime
@@ -109,7 +146,8 @@ func generateReport(d *database.Database, merkles []string, startTime, endTime t
 			windowEnd = endTime
 		}
 
-		window := analyzeWindow(merkles, merkleProofTimes, windowStart, windowEnd)
+		window := analyzeWindow(merkles, merkleProofTimes, inactiveSince, windowStart, windowEnd)
+		window.Disputes = windowDisputes(disputes, window.MissedMerkles, windowStart, windowEnd)
 		windows = append(windows, window)
 
 		windowStart = windowEnd
@@ -128,6 +166,8 @@ func generateReport(d *database.Database, merkles []string, startTime, endTime t
 
 	return &ReportResponse{
 		Merkles: merkles,
+		Owner:   owner,
+		Start:   start,
 		Windows: windows,
 		Summary: ReportSummary{
 			TotalWindows:       len(windows),
@@ -138,9 +178,10 @@ func generateReport(d *database.Database, merkles []string, startTime, endTime t
 }
 
 // analyzeWindow checks which merkles have proofs in the given time window
-func analyzeWindow(merkles []string, merkleProofTimes map[string][]time.Time, windowStart, windowEnd time.Time) ProofWindow {
+func analyzeWindow(merkles []string, merkleProofTimes map[string][]time.Time, inactiveSince map[string]time.Time, windowStart, windowEnd time.Time) ProofWindow {
 	var provenMerkles []string
 	var missedMerkles []string
+	var inactiveMerkles []string
 
 	for _, merkle := range merkles {
 		proofTimes := merkleProofTimes[merkle]
@@ -153,18 +194,45 @@ func analyzeWindow(merkles []string, merkleProofTimes map[string][]time.Time, wi
 			}
 		}
 
+		since, isInactive := inactiveSince[merkle]
+
 		if hasProofInWindow {
 			provenMerkles = append(provenMerkles, merkle)
+		} else if isInactive && since.Before(windowEnd) {
+			inactiveMerkles = append(inactiveMerkles, merkle)
 		} else {
 			missedMerkles = append(missedMerkles, merkle)
 		}
 	}
 
 	return ProofWindow{
-		Start:         windowStart,
-		End:           windowEnd,
-		AllProven:     len(missedMerkles) == 0,
-		ProvenMerkles: provenMerkles,
-		MissedMerkles: missedMerkles,
+		Start:           windowStart,
+		End:             windowEnd,
+		AllProven:       len(missedMerkles) == 0,
+		ProvenMerkles:   provenMerkles,
+		MissedMerkles:   missedMerkles,
+		InactiveMerkles: inactiveMerkles,
 	}
 }
+
+// windowDisputes returns the disputes over missed merkles that were opened from the start of the
+// window until one window duration after it ended, the time a prover has to settle a missed proof
+func windowDisputes(disputes []types.Dispute, missedMerkles []string, windowStart, windowEnd time.Time) []types.Dispute {
+	missed := make(map[string]bool, len(missedMerkles))
+	for _, merkle := range missedMerkles {
+		missed[merkle] = true
+	}
+
+	var windowDisputes []types.Dispute
+	for _, dispute := range disputes {
+		if !missed[dispute.Merkle] {
+			continue
+		}
+		if dispute.OpenedAt.Before(windowStart) || !dispute.OpenedAt.Before(windowEnd.Add(windowDuration)) {
+			continue
+		}
+		windowDisputes = append(windowDisputes, dispute)
+	}
+
+	return windowDisputes
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/JackalLabs/jindexer/database"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// RegisterStatsEndpoint adds the /stats/messages endpoint to the router, showing how many messages of
+// every type URL were included on chain per day, whether the indexer handles them or not
+func RegisterStatsEndpoint(r *gin.Engine, d *database.Database) {
+	r.GET("/stats/messages", func(c *gin.Context) {
+		db := chainDatabase(c, d)
+
+		// Parse optional start and end dates, default to 30 days from current time
+		now := time.Now()
+		endTime := now
+		startTime := now.AddDate(0, 0, -30)
+
+		if startDateStr := c.Query("start_date"); startDateStr != "" {
+			parsedStart, err := time.Parse(time.RFC3339, startDateStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_date format, use RFC3339 (e.g., 2006-01-02T15:04:05Z07:00)"})
+				return
+			}
+			startTime = parsedStart
+		}
+
+		if endDateStr := c.Query("end_date"); endDateStr != "" {
+			parsedEnd, err := time.Parse(time.RFC3339, endDateStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_date format, use RFC3339 (e.g., 2006-01-02T15:04:05Z07:00)"})
+				return
+			}
+			endTime = parsedEnd
+		}
+
+		// Optional type URL prefix, e.g. /canine_chain.storage. for the storage module only
+		prefix := c.Query("prefix")
+
+		totals, err := db.GetMessageTypeTotals(prefix, startTime, endTime)
+		if err != nil {
+			log.Err(err).Msg("failed to query message type totals")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+			return
+		}
+
+		days, err := db.ListMessageTypeDailyStats(prefix, startTime, endTime)
+		if err != nil {
+			log.Err(err).Msg("failed to query daily message type stats")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query database"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"prefix":     prefix,
+			"start_date": startTime,
+			"end_date":   endTime,
+			"totals":     totals,
+			"days":       days,
+		})
+	})
+}
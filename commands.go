@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/JackalLabs/jindexer/database"
+	"github.com/JackalLabs/jindexer/indexer"
+	canine "github.com/jackalLabs/canine-chain/v5/app"
+	"github.com/rs/zerolog/log"
+)
+
+// runCommand executes a one-shot subcommand and exits the process on failure. Cancelling ctx
+// interrupts long running commands, which stop as cleanly as the daemon does.
+func runCommand(ctx context.Context, name string, args []string) {
+	var err error
+	switch name {
+	case "gaps":
+		err = runGaps(ctx, args)
+	case "backfill":
+		err = runBackfill(ctx, args)
+	case "sync":
+		err = runSync(ctx, args)
+	case "cursor":
+		err = runCursor(ctx, args)
+	default:
+		err = fmt.Errorf("unknown command %q", name)
+	}
+
+	if err != nil {
+		log.Err(err).Str("command", name).Msg("command failed")
+		os.Exit(1)
+	}
+}
+
+// commandDatabase connects to the database scoped to the named network (the first configured network
+// if empty) the same way the daemon does.
+func commandDatabase(ctx context.Context, networkName string) (network, *database.Database, error) {
+	n, err := findNetwork(networkName)
+	if err != nil {
+		return n, nil, err
+	}
+
+	d, err := database.NewDatabase()
+	if err != nil {
+		return n, nil, err
+	}
+
+	rpcClient, err := dialNetwork(ctx, n)
+	if err != nil {
+		return n, nil, err
+	}
+
+	d, err = networkDatabase(ctx, d, rpcClient, n)
+	if err != nil {
+		return n, nil, err
+	}
+
+	return n, d, nil
+}
+
+// newCommandIndexer connects to the database and RPC of the named network (the first configured
+// network if empty) the same way the daemon does, for commands that need to fetch or inspect blocks.
+// The caller closes the indexer.
+func newCommandIndexer(ctx context.Context, networkName string) (*indexer.Indexer, error) {
+	n, d, err := commandDatabase(ctx, networkName)
+	if err != nil {
+		return nil, err
+	}
+
+	return indexer.NewIndexer(n.rpcEndpoints(), n.grpcEndpoints(), canine.MakeEncodingConfig(), d, 0, 0, n.indexerConfig())
+}
+
+// runGaps lists the holes in the indexed height sequence and optionally re-indexes them.
+//
+//	jindexer gaps [-network NAME] [-from N] [-to N] [-repair]
+func runGaps(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("gaps", flag.ContinueOnError)
+	networkName := flags.String("network", "", "network to check (defaults to the first configured network)")
+	from := flags.Int64("from", 0, "first height to check (defaults to the lowest indexed height)")
+	to := flags.Int64("to", 0, "last height to check (defaults to the highest indexed height)")
+	repair := flags.Bool("repair", false, "re-index the missing heights")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	i, err := newCommandIndexer(ctx, *networkName)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	gaps, err := i.ScanGaps(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	var missing int64
+	for _, gap := range gaps {
+		missing += gap.Size()
+		fmt.Printf("%d-%d (%d heights)\n", gap.Start, gap.End, gap.Size())
+	}
+	fmt.Printf("%d gaps, %d missing heights\n", len(gaps), missing)
+
+	if !*repair || len(gaps) == 0 {
+		return nil
+	}
+
+	repaired := i.RepairGaps(ctx, gaps)
+	fmt.Printf("repaired %d of %d missing heights\n", repaired, missing)
+	if repaired < missing {
+		return fmt.Errorf("%d heights could not be indexed and were recorded as failed", missing-repaired)
+	}
+
+	return nil
+}
+
+// runBackfill indexes an explicit range of heights and exits once it is done. It can run next to the
+// daemon, heights that are already indexed are skipped.
+//
+//	jindexer backfill [-network NAME] -from N|earliest [-to N]
+func runBackfill(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	networkName := flags.String("network", "", "network to backfill (defaults to the first configured network)")
+	from := flags.String("from", "", "first height to index, or \"earliest\" for the earliest height the RPC still serves")
+	to := flags.Int64("to", 0, "last height to index (defaults to the current network height)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" {
+		return fmt.Errorf("-from is required")
+	}
+
+	i, err := newCommandIndexer(ctx, *networkName)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	fromHeight, toHeight, err := resolveRange(ctx, i, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("chain_id", i.ChainID()).Int64("from", fromHeight).Int64("to", toHeight).Msg("Backfilling range")
+
+	return i.Backfill(ctx, fromHeight, toHeight)
+}
+
+// resolveRange turns the -from and -to flags of a range command into heights. from may be "earliest"
+// for the earliest height the RPC still serves, a to of 0 means the current network height.
+func resolveRange(ctx context.Context, i *indexer.Indexer, from string, to int64) (int64, int64, error) {
+	var fromHeight int64
+	var err error
+	if from == "earliest" {
+		fromHeight, err = i.EarliestAvailableHeight(ctx)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to find the earliest available height: %w", err)
+		}
+	} else {
+		fromHeight, err = strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid -from %q: %w", from, err)
+		}
+	}
+
+	toHeight := to
+	if toHeight == 0 {
+		toHeight, err = i.LatestHeight(ctx)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get the current network height: %w", err)
+		}
+	}
+
+	return fromHeight, toHeight, nil
+}
+
+// runSync re-indexes a large range by splitting it into chunks indexed by several workers at once.
+// Chunk progress is checkpointed, so running the same command again resumes an interrupted sync.
+//
+//	jindexer sync [-network NAME] -from N|earliest [-to N] [-chunk-size N] [-workers N]
+func runSync(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	networkName := flags.String("network", "", "network to sync (defaults to the first configured network)")
+	from := flags.String("from", "", "first height to index, or \"earliest\" for the earliest height the RPC still serves")
+	to := flags.Int64("to", 0, "last height to index (defaults to the current network height)")
+	chunkSize := flags.Int64("chunk-size", 10000, "heights per chunk, keep it the same when resuming")
+	workers := flags.Int("workers", indexerConfig().Workers, "chunks indexed at the same time")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" {
+		return fmt.Errorf("-from is required")
+	}
+
+	i, err := newCommandIndexer(ctx, *networkName)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	fromHeight, toHeight, err := resolveRange(ctx, i, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	return i.Sync(ctx, fromHeight, toHeight, *chunkSize, *workers)
+}
+
+// runCursor inspects or moves the cursor the live indexer resumes from. The daemon must be stopped
+// before moving its cursor, otherwise it overwrites it with its next commit. Heights the indexer walks
+// again after a rewind are skipped if they are already indexed, use gaps -repair for missing ones.
+//
+//	jindexer cursor show [-network NAME]
+//	jindexer cursor rewind [-network NAME] [-name NAME] -to N
+//	jindexer cursor reset [-network NAME] [-name NAME]
+func runCursor(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: cursor show|rewind|reset [flags]")
+	}
+	action := args[0]
+
+	flags := flag.NewFlagSet("cursor "+action, flag.ContinueOnError)
+	networkName := flags.String("network", "", "network of the cursor (defaults to the first configured network)")
+	name := flags.String("name", indexerConfig().Cursor, "name of the cursor")
+	to := flags.Int64("to", 0, "height to rewind the cursor to, the indexer resumes right after it")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	_, d, err := commandDatabase(ctx, *networkName)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "show":
+		cursors, err := d.ListCursors()
+		if err != nil {
+			return err
+		}
+		for _, cursor := range cursors {
+			fmt.Printf("%s\t%d\t%s\t%s\n", cursor.Name, cursor.Height, cursor.BlockHash, cursor.UpdatedAt.Format(time.RFC3339))
+		}
+		fmt.Printf("%d cursors on %s\n", len(cursors), d.ChainID())
+
+	case "rewind":
+		cursor, err := d.GetCursor(*name)
+		if err != nil {
+			return fmt.Errorf("failed to read cursor %s: %w", *name, err)
+		}
+		if *to < 1 || *to >= cursor.Height {
+			return fmt.Errorf("-to must be a height below the cursor at %d", cursor.Height)
+		}
+
+		var blockHash string
+		blocks, err := d.ListBlocksByHeight(*to)
+		if err != nil {
+			return err
+		}
+		if len(blocks) > 0 {
+			blockHash = blocks[0].Hash
+		}
+
+		err = d.SaveCursor(*name, *to, blockHash)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rewound cursor %s from %d to %d\n", *name, cursor.Height, *to)
+
+	case "reset":
+		existed, err := d.DeleteCursor(*name)
+		if err != nil {
+			return err
+		}
+		if !existed {
+			return fmt.Errorf("cursor %s does not exist", *name)
+		}
+		fmt.Printf("reset cursor %s, the indexer resumes after the most recent indexed block or the chain head\n", *name)
+
+	default:
+		return fmt.Errorf("unknown cursor action %q, use show, rewind or reset", action)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"github.com/JackalLabs/jindexer/types"
+	"gorm.io/gorm"
+)
+
+// ForChain returns a view of the database scoped to a single network. Every row written through it
+// is tagged with chainID and every query only sees rows of that network.
+func (d *Database) ForChain(chainID string) *Database {
+	return &Database{
+		db:      d.db,
+		chainID: chainID,
+	}
+}
+
+// ChainID returns the network the database is scoped to, or "" if it sees every network.
+func (d *Database) ChainID() string {
+	return d.chainID
+}
+
+// onChain restricts a query to the database's network, qualifying the chain_id column with table.
+// Databases that are not scoped to a network see every row.
+func (d *Database) onChain(table string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if d.chainID == "" {
+			return db
+		}
+		return db.Where(table+".chain_id = ?", d.chainID)
+	}
+}
+
+// ListChainIDs returns the chain IDs of the networks that have indexed blocks.
+func (d *Database) ListChainIDs() ([]string, error) {
+	var chainIDs []string
+
+	err := d.db.Raw("SELECT DISTINCT COALESCE(chain_id, '') FROM blocks WHERE deleted_at IS NULL ORDER BY 1").
+		Scan(&chainIDs).Error
+
+	return chainIDs, err
+}
+
+// chainModels are the models tagged with a chain ID.
+var chainModels = []interface{}{
+	&types.Block{},
+	&types.Transaction{},
+	&types.PostProof{},
+	&types.File{},
+	&types.Provider{},
+	&types.ProviderEvent{},
+	&types.StoragePlan{},
+	&types.StoragePurchase{},
+	&types.Dispute{},
+	&types.DisputeEvent{},
+	&types.FailedHeight{},
+	&types.SyncChunk{},
+	&types.IndexerCursor{},
+	&types.MessageTypeStat{},
+	&types.MessageTypeDailyStat{},
+}
+
+// AdoptLegacyRows tags the rows indexed before networks were tracked with the database's chain ID,
+// so a database that only ever held one network keeps its history. It returns the number of rows adopted.
+func (d *Database) AdoptLegacyRows() (int64, error) {
+	var adopted int64
+
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range chainModels {
+			result := tx.Unscoped().Model(model).
+				Where("chain_id IS NULL OR chain_id = ''").
+				Update("chain_id", d.chainID)
+			if result.Error != nil {
+				return result.Error
+			}
+			adopted += result.RowsAffected
+		}
+		return nil
+	})
+
+	return adopted, err
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"github.com/JackalLabs/jindexer/types"
+	"gorm.io/gorm/clause"
+)
+
+// GetCursor returns the named cursor, gorm.ErrRecordNotFound if it has never been written.
+func (d *Database) GetCursor(name string) (*types.IndexerCursor, error) {
+	var cursor types.IndexerCursor
+	err := d.db.Model(&types.IndexerCursor{}).
+		Scopes(d.onChain("indexer_cursors")).
+		Where("name = ?", name).
+		First(&cursor).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// ListCursors returns every cursor of the network, by name.
+func (d *Database) ListCursors() ([]types.IndexerCursor, error) {
+	var cursors []types.IndexerCursor
+	err := d.db.Model(&types.IndexerCursor{}).
+		Scopes(d.onChain("indexer_cursors")).
+		Order("name ASC").
+		Find(&cursors).Error
+	return cursors, err
+}
+
+// SaveCursor moves the named cursor to height, creating it if needed. Call it on the Database handed
+// to Transaction so the cursor only moves if the block data is committed with it.
+func (d *Database) SaveCursor(name string, height int64, blockHash string) error {
+	cursor := types.IndexerCursor{
+		ChainID:   d.chainID,
+		Name:      name,
+		Height:    height,
+		BlockHash: blockHash,
+	}
+
+	return d.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"height", "block_hash", "updated_at"}),
+	}).Create(&cursor).Error
+}
+
+// DeleteCursor removes the named cursor, returning whether it existed.
+func (d *Database) DeleteCursor(name string) (bool, error) {
+	result := d.db.Unscoped().
+		Scopes(d.onChain("indexer_cursors")).
+		Where("name = ?", name).
+		Delete(&types.IndexerCursor{})
+	return result.RowsAffected > 0, result.Error
+}
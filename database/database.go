@@ -50,10 +50,38 @@ func initDatabase() (*gorm.DB, error) {
 	err = db.AutoMigrate(
 		&types.PostProof{},
 		&types.Block{},
+		&types.Transaction{},
+		&types.File{},
+		&types.Provider{},
+		&types.ProviderEvent{},
+		&types.StoragePlan{},
+		&types.StoragePurchase{},
+		&types.Dispute{},
+		&types.DisputeEvent{},
+		&types.FailedHeight{},
+		&types.SyncChunk{},
+		&types.IndexerCursor{},
+		&types.MessageTypeStat{},
+		&types.MessageTypeDailyStat{},
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	err = dropLegacyIndexes(db)
+	if err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
+
+// dropLegacyIndexes removes the unique index on block heights from before rows were tagged with a
+// chain ID. It was replaced by an index that includes the chain ID, and would stop a second network
+// from indexing the same heights.
+func dropLegacyIndexes(db *gorm.DB) error {
+	if !db.Migrator().HasIndex(&types.Block{}, "idx_blocks_height") {
+		return nil
+	}
+	return db.Migrator().DropIndex(&types.Block{}, "idx_blocks_height")
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/JackalLabs/jindexer/types"
+	"gorm.io/gorm"
+)
+
+// OpenDispute stores a newly requested attestation or report form.
+func (d *Database) OpenDispute(dispute *types.Dispute) error {
+	dispute.ChainID = d.chainID
+	return d.db.Create(dispute).Error
+}
+
+// GetOpenDispute returns the most recent open form of the given kind for a prover's deal,
+// or nil if there is none.
+func (d *Database) GetOpenDispute(kind string, prover string, merkle string, owner string, start int64) (*types.Dispute, error) {
+	var dispute types.Dispute
+	err := d.db.Scopes(d.onChain("disputes")).Where("kind = ? AND prover = ? AND merkle = ? AND owner = ? AND start = ? AND status = ?",
+		kind, prover, merkle, owner, start, "open").
+		Order("opened_height DESC").
+		First(&dispute).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// UpdateDispute saves the attestation count and status of a dispute.
+func (d *Database) UpdateDispute(dispute *types.Dispute) error {
+	return d.db.Save(dispute).Error
+}
+
+// SaveDisputeEvent records a single attestation or report message.
+func (d *Database) SaveDisputeEvent(event *types.DisputeEvent) error {
+	event.ChainID = d.chainID
+	return d.db.Create(event).Error
+}
+
+// CountDisputeAttesters returns how many distinct attesters have successfully completed a dispute form.
+func (d *Database) CountDisputeAttesters(disputeID uint, action string) (int64, error) {
+	var count int64
+	err := d.db.Model(&types.DisputeEvent{}).
+		Where("dispute_id = ? AND action = ? AND success", disputeID, action).
+		Distinct("creator").
+		Count(&count).Error
+	return count, err
+}
+
+// ListDisputes returns disputes opened between startTime and endTime (inclusive), most recent first,
+// optionally narrowed down to a merkle and/or a prover.
+func (d *Database) ListDisputes(merkle string, prover string, startTime, endTime time.Time) ([]types.Dispute, error) {
+	var disputes []types.Dispute
+
+	query := d.db.Model(&types.Dispute{}).
+		Scopes(d.onChain("disputes")).
+		Where("opened_at >= ? AND opened_at <= ?", startTime, endTime)
+	if merkle != "" {
+		query = query.Where("merkle = ?", merkle)
+	}
+	if prover != "" {
+		query = query.Where("prover = ?", prover)
+	}
+
+	err := query.
+		Order("opened_at DESC").
+		Find(&disputes).Error
+
+	return disputes, err
+}
+
+// ListDisputesForMerkles returns the disputes over any of the given merkles opened between startTime
+// and endTime (inclusive), oldest first.
+func (d *Database) ListDisputesForMerkles(merkles []string, startTime, endTime time.Time) ([]types.Dispute, error) {
+	var disputes []types.Dispute
+
+	err := d.db.Model(&types.Dispute{}).
+		Scopes(d.onChain("disputes")).
+		Where("merkle IN ?", merkles).
+		Where("opened_at >= ? AND opened_at <= ?", startTime, endTime).
+		Order("opened_at ASC").
+		Find(&disputes).Error
+
+	return disputes, err
+}
+
+// ListDisputeEvents returns the messages recorded for a dispute, oldest first.
+func (d *Database) ListDisputeEvents(disputeID uint) ([]types.DisputeEvent, error) {
+	var events []types.DisputeEvent
+
+	err := d.db.Model(&types.DisputeEvent{}).
+		Where("dispute_id = ?", disputeID).
+		Order("id ASC").
+		Preload("Block").
+		Find(&events).Error
+
+	return events, err
+}
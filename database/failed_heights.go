@@ -0,0 +1,49 @@
+package database
+
+import (
+	"github.com/JackalLabs/jindexer/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecordFailedHeight stores a height that could not be indexed, bumping its attempt
+// counter if it was already recorded.
+func (d *Database) RecordFailedHeight(height int64, lastError string) error {
+	failed := types.FailedHeight{
+		ChainID:   d.chainID,
+		Height:    height,
+		Attempts:  1,
+		LastError: lastError,
+	}
+
+	return d.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "chain_id"}, {Name: "height"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"attempts":   gorm.Expr("failed_heights.attempts + 1"),
+			"last_error": lastError,
+			"updated_at": gorm.Expr("NOW()"),
+			"deleted_at": nil,
+		}),
+	}).Create(&failed).Error
+}
+
+// ListFailedHeights returns recorded failed heights, lowest first, limited to the specified count.
+func (d *Database) ListFailedHeights(limit int) ([]types.FailedHeight, error) {
+	var failed []types.FailedHeight
+
+	err := d.db.Model(&types.FailedHeight{}).
+		Scopes(d.onChain("failed_heights")).
+		Order("height ASC").
+		Limit(limit).
+		Find(&failed).Error
+
+	return failed, err
+}
+
+// DeleteFailedHeight removes a height from the failed heights record once it has been indexed.
+func (d *Database) DeleteFailedHeight(height int64) error {
+	return d.db.Unscoped().
+		Scopes(d.onChain("failed_heights")).
+		Where("height = ?", height).
+		Delete(&types.FailedHeight{}).Error
+}
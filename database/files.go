@@ -0,0 +1,136 @@
+package database
+
+import (
+	"time"
+
+	"github.com/JackalLabs/jindexer/types"
+	"gorm.io/gorm/clause"
+)
+
+// SaveFile stores a file posted on chain. Posting the same deal twice is a no-op.
+func (d *Database) SaveFile(file *types.File) error {
+	file.ChainID = d.chainID
+	return d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(file).Error
+}
+
+// ListFilesByMerkle returns every file stored under the given merkle, most recently posted first.
+func (d *Database) ListFilesByMerkle(merkle string) ([]types.File, error) {
+	var files []types.File
+
+	err := d.db.Model(&types.File{}).
+		Scopes(d.onChain("files")).
+		Where("merkle = ?", merkle).
+		Order("start DESC").
+		Preload("Block").
+		Find(&files).Error
+
+	return files, err
+}
+
+// GetTotalFileCount returns the total number of files in the database.
+func (d *Database) GetTotalFileCount() (int64, error) {
+	var count int64
+	err := d.db.Model(&types.File{}).Scopes(d.onChain("files")).Count(&count).Error
+	return count, err
+}
+
+// MerklePostedTime holds the time a merkle was first posted as a file.
+type MerklePostedTime struct {
+	Merkle     string
+	PostedTime time.Time
+}
+
+// GetUnprovenMerklePostedTimes returns the merkles of files that have never had a successful proof,
+// along with the time they were first posted.
+func (d *Database) GetUnprovenMerklePostedTimes() ([]MerklePostedTime, error) {
+	var results []MerklePostedTime
+
+	err := d.db.Model(&types.File{}).
+		Select("files.merkle, MIN(blocks.time) as posted_time").
+		Scopes(d.onChain("files")).
+		Joins("INNER JOIN blocks ON files.block_id = blocks.id").
+		Where("NOT EXISTS (SELECT 1 FROM post_proofs WHERE post_proofs.merkle = files.merkle AND post_proofs.chain_id IS NOT DISTINCT FROM files.chain_id AND post_proofs.code = 0 AND NOT post_proofs.rejected AND post_proofs.deleted_at IS NULL)").
+		Group("files.merkle").
+		Scan(&results).Error
+
+	return results, err
+}
+
+// MarkFileDeleted records that a file was deleted by its owner at the given block. If the file was
+// posted before indexing started a tombstone row is created, so the merkle still stops counting.
+func (d *Database) MarkFileDeleted(merkle string, owner string, start int64, block types.Block, transactionID uint) error {
+	result := d.db.Model(&types.File{}).
+		Scopes(d.onChain("files")).
+		Where("merkle = ? AND owner = ? AND start = ?", merkle, owner, start).
+		Updates(map[string]interface{}{
+			"ended_height": block.Height,
+			"ended_at":     block.Time,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	endedAt := block.Time
+	tombstone := types.File{
+		Merkle:        merkle,
+		Owner:         owner,
+		Start:         start,
+		EndedHeight:   block.Height,
+		EndedAt:       &endedAt,
+		BlockId:       block.ID,
+		TransactionId: transactionID,
+	}
+	return d.SaveFile(&tombstone)
+}
+
+// MerkleInactivity holds the time a merkle stopped needing proofs.
+type MerkleInactivity struct {
+	Merkle        string
+	InactiveSince time.Time
+}
+
+// GetInactiveMerkles returns the merkles whose files have all been deleted or have expired, with the
+// time the last of them ended. Expiry only counts once the expiry height has been indexed.
+// When merkles are given only those are considered, and a non-empty owner or non-zero start only
+// considers the files of that deal, so a deal its owner ended is inactive even if others still store the merkle.
+func (d *Database) GetInactiveMerkles(owner string, start int64, merkles ...string) ([]MerkleInactivity, error) {
+	var results []MerkleInactivity
+
+	query := d.db.Model(&types.File{}).
+		Select("files.merkle, MAX(COALESCE(files.ended_at, expiry.time)) as inactive_since").
+		Scopes(d.onChain("files")).
+		Joins("LEFT JOIN blocks expiry ON files.ended_at IS NULL AND files.expires > 0 AND expiry.chain_id IS NOT DISTINCT FROM files.chain_id AND expiry.height = files.expires AND expiry.deleted_at IS NULL")
+	if len(merkles) > 0 {
+		query = query.Where("files.merkle IN ?", merkles)
+	}
+	if owner != "" {
+		query = query.Where("files.owner = ?", owner)
+	}
+	if start != 0 {
+		query = query.Where("files.start = ?", start)
+	}
+
+	err := query.
+		Group("files.merkle").
+		Having("bool_and(files.ended_at IS NOT NULL OR expiry.id IS NOT NULL)").
+		Scan(&results).Error
+
+	return results, err
+}
+
+// ListFilesByOwner returns every file owned by the given address, most recently posted first.
+func (d *Database) ListFilesByOwner(owner string) ([]types.File, error) {
+	var files []types.File
+
+	err := d.db.Model(&types.File{}).
+		Scopes(d.onChain("files")).
+		Where("owner = ?", owner).
+		Order("start DESC").
+		Preload("Block").
+		Find(&files).Error
+
+	return files, err
+}
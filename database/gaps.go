@@ -0,0 +1,53 @@
+package database
+
+import (
+	"github.com/JackalLabs/jindexer/types"
+)
+
+// HeightRange is an inclusive range of block heights.
+type HeightRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// Size returns the number of heights covered by the range.
+func (r HeightRange) Size() int64 {
+	return r.End - r.Start + 1
+}
+
+// GetLowestBlockHeight returns the height of the earliest saved block.
+// Returns 0 and an error if no blocks are found or if there's a database error.
+func (d *Database) GetLowestBlockHeight() (int64, error) {
+	var block types.Block
+	err := d.db.Model(&types.Block{}).
+		Scopes(d.onChain("blocks")).
+		Order("height ASC").
+		First(&block).Error
+	if err != nil {
+		return 0, err
+	}
+	return block.Height, nil
+}
+
+// ListBlockGaps returns the ranges of heights between from and to (inclusive) that have no saved block.
+// The heights are generated with generate_series and anti-joined against the blocks table, then
+// consecutive missing heights are collapsed into ranges.
+func (d *Database) ListBlockGaps(from, to int64) ([]HeightRange, error) {
+	var gaps []HeightRange
+
+	err := d.db.Raw(`
+		SELECT MIN(missing.height) AS start, MAX(missing.height) AS "end"
+		FROM (
+			SELECT s.height, s.height - ROW_NUMBER() OVER (ORDER BY s.height) AS grp
+			FROM generate_series(?::bigint, ?::bigint) AS s(height)
+			WHERE NOT EXISTS (
+				SELECT 1 FROM blocks b
+				WHERE b.height = s.height AND b.deleted_at IS NULL AND (? = '' OR b.chain_id = ?)
+			)
+		) missing
+		GROUP BY missing.grp
+		ORDER BY start`, from, to, d.chainID, d.chainID).
+		Scan(&gaps).Error
+
+	return gaps, err
+}
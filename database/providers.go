@@ -0,0 +1,112 @@
+package database
+
+import (
+	"sort"
+
+	"github.com/JackalLabs/jindexer/types"
+)
+
+// UpdateProvider records a change to a provider in its history and rebuilds the provider's current
+// state from that history in chain order, so changes indexed out of order (backfill, gap repair)
+// end up where they belong instead of overwriting newer ones. Call it on the Database handed to
+// Transaction, the history is read back with the blocks of the same commit.
+func (d *Database) UpdateProvider(event *types.ProviderEvent) error {
+	event.ChainID = d.chainID
+	err := d.db.Create(event).Error
+	if err != nil {
+		return err
+	}
+
+	provider := types.Provider{ChainID: d.chainID, Address: event.Address}
+	err = d.db.Where(types.Provider{ChainID: d.chainID, Address: event.Address}).FirstOrCreate(&provider).Error
+	if err != nil {
+		return err
+	}
+
+	var events []types.ProviderEvent
+	err = d.db.Model(&types.ProviderEvent{}).
+		Scopes(d.onChain("provider_events")).
+		Where("address = ?", event.Address).
+		Preload("Block").
+		Find(&events).Error
+	if err != nil {
+		return err
+	}
+
+	replayProviderEvents(&provider, events)
+
+	return d.db.Model(&provider).
+		Select("ip", "keybase", "total_space", "active", "init_height", "shutdown_height", "last_update_height").
+		Updates(&provider).Error
+}
+
+// replayProviderEvents sets provider to the state its events leave it in when applied in chain order.
+// A provider first seen through a message other than init was initialized before the indexed range,
+// so it is active unless a shutdown was indexed.
+func replayProviderEvents(provider *types.Provider, events []types.ProviderEvent) {
+	sort.SliceStable(events, func(a, b int) bool {
+		if events[a].Block.Height != events[b].Block.Height {
+			return events[a].Block.Height < events[b].Block.Height
+		}
+		// transactions of a block are saved in block order, and so are the messages of a transaction
+		if events[a].TransactionId != events[b].TransactionId {
+			return events[a].TransactionId < events[b].TransactionId
+		}
+		return events[a].ID < events[b].ID
+	})
+
+	provider.IP = ""
+	provider.Keybase = ""
+	provider.TotalSpace = 0
+	provider.Active = true
+	provider.InitHeight = 0
+	provider.ShutdownHeight = 0
+	provider.LastUpdateHeight = 0
+
+	for _, event := range events {
+		height := event.Block.Height
+		switch event.Action {
+		case "init":
+			provider.IP = event.IP
+			provider.Keybase = event.Keybase
+			provider.TotalSpace = event.TotalSpace
+			provider.Active = true
+			provider.InitHeight = height
+			provider.ShutdownHeight = 0
+		case "set_ip":
+			provider.IP = event.IP
+		case "set_total_space":
+			provider.TotalSpace = event.TotalSpace
+		case "set_keybase":
+			provider.Keybase = event.Keybase
+		case "shutdown":
+			provider.Active = false
+			provider.ShutdownHeight = height
+		}
+		provider.LastUpdateHeight = height
+	}
+}
+
+// GetProvider returns the current state of the provider with the given address.
+func (d *Database) GetProvider(address string) (*types.Provider, error) {
+	var provider types.Provider
+	err := d.db.Scopes(d.onChain("providers")).Where("address = ?", address).First(&provider).Error
+	if err != nil {
+		return nil, err
+	}
+	return &provider, nil
+}
+
+// ListProviderEvents returns the change history of a provider, most recent first.
+func (d *Database) ListProviderEvents(address string) ([]types.ProviderEvent, error) {
+	var events []types.ProviderEvent
+
+	err := d.db.Model(&types.ProviderEvent{}).
+		Scopes(d.onChain("provider_events")).
+		Where("address = ?", address).
+		Order("id DESC").
+		Preload("Block").
+		Find(&events).Error
+
+	return events, err
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/JackalLabs/jindexer/types"
+	"gorm.io/gorm"
+)
+
+// providerEvent builds a change indexed as the id-th event, at height.
+func providerEvent(id uint, height int64, action string, ip string) types.ProviderEvent {
+	return types.ProviderEvent{
+		Model:   gorm.Model{ID: id},
+		Action:  action,
+		IP:      ip,
+		Keybase: action + "-keybase",
+		Block:   types.Block{Height: height},
+	}
+}
+
+func TestReplayProviderEventsOutOfOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		events         []types.ProviderEvent // in the order they were indexed
+		ip             string
+		keybase        string
+		active         bool
+		initHeight     int64
+		shutdownHeight int64
+		lastUpdate     int64
+	}{
+		{
+			name: "first seen after init",
+			events: []types.ProviderEvent{
+				providerEvent(1, 1200, "set_keybase", ""),
+			},
+			keybase:    "set_keybase-keybase",
+			active:     true,
+			lastUpdate: 1200,
+		},
+		{
+			name: "init backfilled after a later change",
+			events: []types.ProviderEvent{
+				providerEvent(1, 1200, "set_keybase", ""),
+				providerEvent(2, 100, "init", "init.example.com"),
+			},
+			ip:         "init.example.com",
+			keybase:    "set_keybase-keybase",
+			active:     true,
+			initHeight: 100,
+			lastUpdate: 1200,
+		},
+		{
+			name: "backfill walking up to a later change",
+			events: []types.ProviderEvent{
+				providerEvent(1, 1200, "set_keybase", ""),
+				providerEvent(2, 100, "init", "init.example.com"),
+				providerEvent(3, 500, "set_ip", "new.example.com"),
+			},
+			ip:         "new.example.com",
+			keybase:    "set_keybase-keybase",
+			active:     true,
+			initHeight: 100,
+			lastUpdate: 1200,
+		},
+		{
+			name: "backfilled change older than a shutdown",
+			events: []types.ProviderEvent{
+				providerEvent(1, 900, "shutdown", ""),
+				providerEvent(2, 500, "set_ip", "new.example.com"),
+			},
+			ip:             "new.example.com",
+			active:         false,
+			shutdownHeight: 900,
+			lastUpdate:     900,
+		},
+		{
+			name: "init backfilled between a shutdown and a re-init",
+			events: []types.ProviderEvent{
+				providerEvent(1, 900, "shutdown", ""),
+				providerEvent(2, 1000, "init", "again.example.com"),
+				providerEvent(3, 100, "init", "init.example.com"),
+			},
+			ip:         "again.example.com",
+			keybase:    "init-keybase",
+			active:     true,
+			initHeight: 1000,
+			lastUpdate: 1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := types.Provider{Address: "jkl1provider"}
+			replayProviderEvents(&provider, tt.events)
+
+			if provider.IP != tt.ip {
+				t.Errorf("ip = %q, want %q", provider.IP, tt.ip)
+			}
+			if provider.Keybase != tt.keybase {
+				t.Errorf("keybase = %q, want %q", provider.Keybase, tt.keybase)
+			}
+			if provider.Active != tt.active {
+				t.Errorf("active = %v, want %v", provider.Active, tt.active)
+			}
+			if provider.InitHeight != tt.initHeight {
+				t.Errorf("init height = %d, want %d", provider.InitHeight, tt.initHeight)
+			}
+			if provider.ShutdownHeight != tt.shutdownHeight {
+				t.Errorf("shutdown height = %d, want %d", provider.ShutdownHeight, tt.shutdownHeight)
+			}
+			if provider.LastUpdateHeight != tt.lastUpdate {
+				t.Errorf("last update height = %d, want %d", provider.LastUpdateHeight, tt.lastUpdate)
+			}
+		})
+	}
+}
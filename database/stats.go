@@ -0,0 +1,92 @@
+package database
+
+import (
+	"time"
+
+	"github.com/JackalLabs/jindexer/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MessageTypeTotal is the number of messages of a type URL over a range of days.
+type MessageTypeTotal struct {
+	TypeURL string `json:"typeUrl"`
+	Count   int64  `json:"count"`
+	Failed  int64  `json:"failed"`
+}
+
+// SaveMessageTypeStats stores the message counts of block and adds them to the daily rollup of the
+// block's UTC day. Call it on the Database handed to Transaction so the rollup only counts committed blocks.
+func (d *Database) SaveMessageTypeStats(block types.Block, stats []types.MessageTypeStat) error {
+	if len(stats) == 0 {
+		return nil
+	}
+
+	day := block.Time.UTC().Truncate(24 * time.Hour)
+	daily := make([]types.MessageTypeDailyStat, len(stats))
+	for idx := range stats {
+		stats[idx].ChainID = d.chainID
+		stats[idx].Height = block.Height
+		stats[idx].BlockId = block.ID
+
+		daily[idx] = types.MessageTypeDailyStat{
+			ChainID: d.chainID,
+			Day:     day,
+			TypeURL: stats[idx].TypeURL,
+			Count:   stats[idx].Count,
+			Failed:  stats[idx].Failed,
+		}
+	}
+
+	err := d.db.Omit("Block").Create(&stats).Error
+	if err != nil {
+		return err
+	}
+
+	return d.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "chain_id"}, {Name: "day"}, {Name: "type_url"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count":      gorm.Expr("message_type_daily_stats.count + excluded.count"),
+			"failed":     gorm.Expr("message_type_daily_stats.failed + excluded.failed"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+	}).Create(&daily).Error
+}
+
+// ListMessageTypeDailyStats returns the daily message counts from the day of startTime to the day of
+// endTime, oldest first. A non-empty typeURLPrefix (e.g. "/canine_chain.storage.") only keeps the
+// matching type URLs.
+func (d *Database) ListMessageTypeDailyStats(typeURLPrefix string, startTime, endTime time.Time) ([]types.MessageTypeDailyStat, error) {
+	var stats []types.MessageTypeDailyStat
+
+	err := d.messageTypeDays(typeURLPrefix, startTime, endTime).
+		Order("day ASC, type_url ASC").
+		Find(&stats).Error
+
+	return stats, err
+}
+
+// GetMessageTypeTotals sums the daily message counts per type URL over the same days as
+// ListMessageTypeDailyStats, busiest type first.
+func (d *Database) GetMessageTypeTotals(typeURLPrefix string, startTime, endTime time.Time) ([]MessageTypeTotal, error) {
+	var totals []MessageTypeTotal
+
+	err := d.messageTypeDays(typeURLPrefix, startTime, endTime).
+		Select("type_url, SUM(count) AS count, SUM(failed) AS failed").
+		Group("type_url").
+		Order("count DESC, type_url ASC").
+		Scan(&totals).Error
+
+	return totals, err
+}
+
+// messageTypeDays selects the daily message counts of a date range, see ListMessageTypeDailyStats.
+func (d *Database) messageTypeDays(typeURLPrefix string, startTime, endTime time.Time) *gorm.DB {
+	query := d.db.Model(&types.MessageTypeDailyStat{}).
+		Scopes(d.onChain("message_type_daily_stats")).
+		Where("day >= ? AND day <= ?", startTime.UTC().Truncate(24*time.Hour), endTime.UTC().Truncate(24*time.Hour))
+	if typeURLPrefix != "" {
+		query = query.Where("type_url LIKE ?", typeURLPrefix+"%")
+	}
+	return query
+}
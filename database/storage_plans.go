@@ -0,0 +1,70 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/JackalLabs/jindexer/types"
+	"gorm.io/gorm"
+)
+
+// SaveStoragePurchase records a storage purchase and makes it the owner's current plan.
+// A purchase made while the previous plan was still running is recorded as an upgrade.
+// Purchases older than the current plan, as seen when heights are indexed out of order, are only
+// recorded in the history.
+func (d *Database) SaveStoragePurchase(purchase *types.StoragePurchase, height int64) error {
+	var plan types.StoragePlan
+	err := d.db.Scopes(d.onChain("storage_plans")).Where("owner = ?", purchase.Owner).First(&plan).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	purchase.Kind = "buy"
+	if plan.ID != 0 && plan.End.After(purchase.Start) {
+		purchase.Kind = "upgrade"
+	}
+
+	purchase.ChainID = d.chainID
+	if plan.ID != 0 && plan.LastPurchaseHeight > height {
+		return d.db.Create(purchase).Error
+	}
+
+	plan.ChainID = d.chainID
+	plan.Owner = purchase.Owner
+	plan.Bytes = purchase.Bytes
+	plan.DurationDays = purchase.DurationDays
+	plan.PaymentDenom = purchase.PaymentDenom
+	plan.Start = purchase.Start
+	plan.End = purchase.End
+	plan.LastPurchaseHeight = height
+
+	err = d.db.Save(&plan).Error
+	if err != nil {
+		return err
+	}
+
+	return d.db.Create(purchase).Error
+}
+
+// GetStoragePlan returns the current storage plan of an owner.
+func (d *Database) GetStoragePlan(owner string) (*types.StoragePlan, error) {
+	var plan types.StoragePlan
+	err := d.db.Scopes(d.onChain("storage_plans")).Where("owner = ?", owner).First(&plan).Error
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// ListStoragePurchases returns the purchase history of an owner, most recent first.
+func (d *Database) ListStoragePurchases(owner string) ([]types.StoragePurchase, error) {
+	var purchases []types.StoragePurchase
+
+	err := d.db.Model(&types.StoragePurchase{}).
+		Scopes(d.onChain("storage_purchases")).
+		Where("owner = ?", owner).
+		Order("id DESC").
+		Preload("Block").
+		Find(&purchases).Error
+
+	return purchases, err
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"github.com/JackalLabs/jindexer/types"
+	"gorm.io/gorm/clause"
+)
+
+// PlanSyncChunks splits from-to (inclusive) into chunks of size heights, recording the chunks that
+// have not been planned before, and returns the chunks of the range that are not done yet, lowest first.
+// Planning the same range with the same size again resumes the earlier chunks.
+func (d *Database) PlanSyncChunks(from, to, size int64) ([]types.SyncChunk, error) {
+	var chunks []types.SyncChunk
+	for start := from; start <= to; start += size {
+		end := start + size - 1
+		if end > to {
+			end = to
+		}
+		chunks = append(chunks, types.SyncChunk{
+			ChainID: d.chainID,
+			Start:   start,
+			End:     end,
+			Next:    start,
+		})
+	}
+
+	err := d.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(chunks, 1000).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []types.SyncChunk
+	err = d.db.Model(&types.SyncChunk{}).
+		Scopes(d.onChain("sync_chunks")).
+		Where("start >= ? AND \"end\" <= ? AND NOT done", from, to).
+		Order("start ASC").
+		Find(&pending).Error
+
+	return pending, err
+}
+
+// CheckpointSyncChunk records how far a chunk has been indexed.
+func (d *Database) CheckpointSyncChunk(id uint, next int64, failed int64, done bool) error {
+	return d.db.Model(&types.SyncChunk{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"next":   next,
+			"failed": failed,
+			"done":   done,
+		}).Error
+}
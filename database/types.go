@@ -10,6 +10,9 @@ import (
 
 type Database struct {
 	db *gorm.DB
+
+	// chainID is the network the database is scoped to, see ForChain
+	chainID string
 }
 
 func NewDatabase() (*Database, error) {
@@ -25,25 +28,55 @@ func NewDatabase() (*Database, error) {
 	return &d, nil
 }
 
+// Close closes the connections to the database. Every view returned by ForChain shares them.
+func (d *Database) Close() error {
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// Transaction runs fn inside a single database transaction. Every write made through the
+// Database handed to fn is committed together, or rolled back if fn returns an error.
+func (d *Database) Transaction(fn func(tx *Database) error) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&Database{db: tx, chainID: d.chainID})
+	})
+}
+
 func (d *Database) SaveBlock(block *types.Block) error {
+	block.ChainID = d.chainID
 	return d.db.Create(block).Error
 }
 
 // BlockExistsByHeight checks if a block with the given height has been saved before
 func (d *Database) BlockExistsByHeight(height int64) (bool, error) {
 	var count int64
-	err := d.db.Model(&types.Block{}).Where("height = ?", height).Count(&count).Error
+	err := d.db.Model(&types.Block{}).Scopes(d.onChain("blocks")).Where("height = ?", height).Count(&count).Error
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
+// ListBlocksByHeight returns the saved blocks at the given heights, heights that are not indexed are
+// left out.
+func (d *Database) ListBlocksByHeight(heights ...int64) ([]types.Block, error) {
+	var blocks []types.Block
+	err := d.db.Model(&types.Block{}).
+		Scopes(d.onChain("blocks")).
+		Where("height IN ?", heights).
+		Find(&blocks).Error
+	return blocks, err
+}
+
 // GetMostRecentBlockHeight returns the height of the most recently saved block.
 // Returns 0 and an error if no blocks are found or if there's a database error.
 func (d *Database) GetMostRecentBlockHeight() (int64, error) {
 	var block types.Block
 	err := d.db.Model(&types.Block{}).
+		Scopes(d.onChain("blocks")).
 		Order("height DESC").
 		First(&block).Error
 	if err != nil {
@@ -52,19 +85,42 @@ func (d *Database) GetMostRecentBlockHeight() (int64, error) {
 	return block.Height, nil
 }
 
+func (d *Database) SaveTransaction(transaction *types.Transaction) error {
+	transaction.ChainID = d.chainID
+	return d.db.Create(transaction).Error
+}
+
+// provenProof matches the proofs the chain accepted: the tx succeeded and the proof was not rejected.
+const provenProof = "post_proofs.code = 0 AND NOT post_proofs.rejected"
+
 func (d *Database) SavePostProof(postProof *types.PostProof) error {
+	postProof.ChainID = d.chainID
 	return d.db.Create(postProof).Error
 }
 
 // ListProofsByMerkleAndTimeRange returns all proofs for a given merkle where the referenced block's time
 // is between startTime and endTime (inclusive), ordered by block date (most recent first).
-func (d *Database) ListProofsByMerkleAndTimeRange(merkle string, startTime, endTime time.Time) ([]types.PostProof, error) {
+// An owner and start narrow the proofs down to a single file deal, "" and 0 match any deal.
+// Proofs from failed transactions and rejected proofs are only included when includeFailed is set.
+func (d *Database) ListProofsByMerkleAndTimeRange(merkle string, owner string, start int64, startTime, endTime time.Time, includeFailed bool) ([]types.PostProof, error) {
 	var proofs []types.PostProof
 
-	err := d.db.Model(&types.PostProof{}).
+	query := d.db.Model(&types.PostProof{}).
+		Scopes(d.onChain("post_proofs")).
 		Joins("INNER JOIN blocks ON post_proofs.block_id = blocks.id").
 		Where("post_proofs.merkle = ?", merkle).
-		Where("blocks.time >= ? AND blocks.time <= ?", startTime, endTime).
+		Where("blocks.time >= ? AND blocks.time <= ?", startTime, endTime)
+	if owner != "" {
+		query = query.Where("post_proofs.owner = ?", owner)
+	}
+	if start != 0 {
+		query = query.Where("post_proofs.start = ?", start)
+	}
+	if !includeFailed {
+		query = query.Where(provenProof)
+	}
+
+	err := query.
 		Order("blocks.time DESC").
 		Preload("Block").
 		Find(&proofs).Error
@@ -77,6 +133,7 @@ func (d *Database) ListRecentProofs(limit int) ([]types.PostProof, error) {
 	var proofs []types.PostProof
 
 	err := d.db.Model(&types.PostProof{}).
+		Scopes(d.onChain("post_proofs")).
 		Joins("INNER JOIN blocks ON post_proofs.block_id = blocks.id").
 		Order("blocks.time DESC").
 		Limit(limit).
@@ -91,6 +148,7 @@ func (d *Database) ListProofsByID(limit int) ([]types.PostProof, error) {
 	var proofs []types.PostProof
 
 	err := d.db.Model(&types.PostProof{}).
+		Scopes(d.onChain("post_proofs")).
 		Order("id DESC").
 		Limit(limit).
 		Preload("Block").
@@ -106,22 +164,78 @@ type MerkleLastProof struct {
 }
 
 // GetMerkleLastProofTimes returns the most recent block time per merkle using
-// a SQL aggregate instead of loading individual rows.
-func (d *Database) GetMerkleLastProofTimes() ([]MerkleLastProof, error) {
+// a SQL aggregate instead of loading individual rows. Proofs from failed transactions and proofs the
+// chain rejected are ignored.
+// When merkles are given only those are considered.
+func (d *Database) GetMerkleLastProofTimes(merkles ...string) ([]MerkleLastProof, error) {
 	var results []MerkleLastProof
 
-	err := d.db.Model(&types.PostProof{}).
+	query := d.db.Model(&types.PostProof{}).
 		Select("post_proofs.merkle, MAX(blocks.time) as last_proof_time").
+		Scopes(d.onChain("post_proofs")).
 		Joins("INNER JOIN blocks ON post_proofs.block_id = blocks.id").
+		Where(provenProof)
+	if len(merkles) > 0 {
+		query = query.Where("post_proofs.merkle IN ?", merkles)
+	}
+
+	err := query.
 		Group("post_proofs.merkle").
 		Scan(&results).Error
 
 	return results, err
 }
 
-// GetTotalProofCount returns the total number of proofs in the database.
+// GetTotalProofCount returns the total number of proofs the chain accepted.
 func (d *Database) GetTotalProofCount() (int64, error) {
 	var count int64
-	err := d.db.Model(&types.PostProof{}).Count(&count).Error
+	err := d.db.Model(&types.PostProof{}).Scopes(d.onChain("post_proofs")).Where(provenProof).Count(&count).Error
 	return count, err
 }
+
+// ProverInvalidProofs holds how many of a prover's proofs were checked offline and how many of them failed.
+type ProverInvalidProofs struct {
+	Prover   string `json:"prover"`
+	Verified int64  `json:"verified"`
+	Invalid  int64  `json:"invalid"`
+}
+
+// GetInvalidProofCounts returns per prover how many proofs failed offline verification, for the
+// provers that submitted at least one invalid proof. Proofs indexed before verification existed are
+// not counted. When provers are given only those are considered, whether they have invalid proofs or not.
+func (d *Database) GetInvalidProofCounts(provers ...string) ([]ProverInvalidProofs, error) {
+	var results []ProverInvalidProofs
+
+	query := d.db.Model(&types.PostProof{}).
+		Select("prover, COUNT(*) FILTER (WHERE verified) AS verified, COUNT(*) FILTER (WHERE NOT verified) AS invalid").
+		Scopes(d.onChain("post_proofs")).
+		Where("verified IS NOT NULL")
+	if len(provers) > 0 {
+		query = query.Where("prover IN ?", provers)
+	} else {
+		query = query.Having("COUNT(*) FILTER (WHERE NOT verified) > 0")
+	}
+
+	err := query.
+		Group("prover").
+		Order("invalid DESC").
+		Scan(&results).Error
+
+	return results, err
+}
+
+// ListInvalidProofs returns the most recent proofs of a prover that failed offline verification.
+func (d *Database) ListInvalidProofs(prover string, limit int) ([]types.PostProof, error) {
+	var proofs []types.PostProof
+
+	err := d.db.Model(&types.PostProof{}).
+		Scopes(d.onChain("post_proofs")).
+		Joins("INNER JOIN blocks ON post_proofs.block_id = blocks.id").
+		Where("post_proofs.prover = ? AND NOT post_proofs.verified", prover).
+		Order("blocks.time DESC").
+		Limit(limit).
+		Preload("Block").
+		Find(&proofs).Error
+
+	return proofs, err
+}
@@ -3,12 +3,14 @@ module github.com/JackalLabs/jindexer
 go 1.25.3
 
 require (
+	github.com/cometbft/cometbft-db v0.7.0
 	github.com/cosmos/cosmos-sdk v0.45.17
 	github.com/gin-gonic/gin v1.8.1
 	github.com/jackalLabs/canine-chain/v5 v5.0.1
 	github.com/prometheus/client_golang v1.18.0
 	github.com/rs/zerolog v1.33.0
 	github.com/tendermint/tendermint v0.34.27
+	github.com/wealdtech/go-merkletree/v2 v2.6.0
 	google.golang.org/grpc v1.61.1
 	gorm.io/driver/postgres v1.6.0
 	gorm.io/gorm v1.31.1
@@ -38,7 +40,6 @@ require (
 	github.com/cockroachdb/pebble v1.0.0 // indirect
 	github.com/cockroachdb/redact v1.1.3 // indirect
 	github.com/coinbase/rosetta-sdk-go v0.7.9 // indirect
-	github.com/cometbft/cometbft-db v0.7.0 // indirect
 	github.com/confio/ics23/go v0.9.1 // indirect
 	github.com/cosmos/btcutil v1.0.5 // indirect
 	github.com/cosmos/cosmos-db v0.0.0-20221226095112-f3c38ecb5e32 // indirect
@@ -158,7 +159,6 @@ require (
 	github.com/tendermint/tm-db v0.6.7 // indirect
 	github.com/tidwall/btree v1.5.0 // indirect
 	github.com/ugorji/go/codec v1.2.7 // indirect
-	github.com/wealdtech/go-merkletree/v2 v2.6.0 // indirect
 	github.com/zeebo/blake3 v0.2.4 // indirect
 	github.com/zondax/hid v0.9.2 // indirect
 	github.com/zondax/ledger-go v0.14.3 // indirect
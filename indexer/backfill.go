@@ -0,0 +1,115 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tendermint/tendermint/rpc/client/http"
+)
+
+// progressInterval is how often a bounded run logs its progress.
+const progressInterval = 10 * time.Second
+
+// Backfill indexes every height from from to to (inclusive) through the normal pipeline and returns
+// once the range is done. Heights that are already indexed are skipped, so it can run next to the
+// live follower. It returns an error if any height had to be recorded as failed, or ctx's error if it
+// was cancelled before the range was done.
+func (i *Indexer) Backfill(ctx context.Context, from, to int64) error {
+	if from < 1 || to < from {
+		return fmt.Errorf("invalid backfill range %d-%d", from, to)
+	}
+
+	i.startHeight = from
+	i.currentHeight = from
+	i.endHeight = to + 1 // the end height is exclusive
+
+	err := i.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	failed := i.failedCount.Load()
+	if failed > 0 {
+		return fmt.Errorf("%d heights could not be indexed and were recorded as failed", failed)
+	}
+	return nil
+}
+
+// EarliestAvailableHeight returns the lowest height any of the RPC endpoints still serves.
+func (i *Indexer) EarliestAvailableHeight(ctx context.Context) (int64, error) {
+	i.rpc.check(ctx, i.chainID)
+
+	var earliest int64
+	found := false
+	for _, e := range i.rpc.endpoints {
+		e.mu.Lock()
+		if !e.wrongChain && e.latestHeight > 0 && (!found || e.earliestHeight < earliest) {
+			earliest = e.earliestHeight
+			found = true
+		}
+		e.mu.Unlock()
+	}
+
+	if !found {
+		return 0, errNoEndpoints
+	}
+	// nodes that never pruned report 0 or 1
+	if earliest < 1 {
+		earliest = 1
+	}
+	return earliest, nil
+}
+
+// LatestHeight returns the latest height of the network.
+func (i *Indexer) LatestHeight(ctx context.Context) (int64, error) {
+	var latest int64
+	err := i.rpc.call(ctx, 0, func(c *http.HTTP) error {
+		abciInfo, err := c.ABCIInfo(ctx)
+		if err != nil {
+			return err
+		}
+		latest = abciInfo.Response.LastBlockHeight
+		return nil
+	})
+	if err == nil && latest == 0 {
+		err = errors.New("network has no blocks yet")
+	}
+	return latest, err
+}
+
+// reportProgress logs how far a run over total heights has come, its rate and the estimated time
+// left, until ctx is cancelled.
+func (i *Indexer) reportProgress(ctx context.Context, total int64) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	started := time.Now()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		done := i.committedCount.Load() + i.failedCount.Load()
+		elapsed := time.Since(started)
+		rate := float64(done) / elapsed.Seconds()
+
+		event := log.Info().
+			Str("chain_id", i.chainID).
+			Int64("done", done).
+			Int64("total", total).
+			Float64("percent", float64(done)*100/float64(total)).
+			Float64("blocks_per_second", rate).
+			Int64("failed", i.failedCount.Load())
+		if rate > 0 {
+			eta := time.Duration(float64(total-done)/rate) * time.Second
+			event = event.Dur("eta", eta.Round(time.Second))
+		}
+		event.Msg("Indexing progress")
+	}
+}
@@ -0,0 +1,71 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/JackalLabs/jindexer/database"
+	types2 "github.com/JackalLabs/jindexer/types"
+
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+// errBlockMismatch means a fetched block does not link up with the blocks already indexed around it.
+// Either the RPC served a block from another fork or chain, or the index itself is corrupted, so it is
+// never retried and halts the indexer instead of being recorded as a failed height.
+var errBlockMismatch = errors.New("block does not match the indexed chain")
+
+// newBlockRecord builds the database record of a fetched block from its header.
+func newBlockRecord(block *tmtypes.Block) types2.Block {
+	return types2.Block{
+		Height:          block.Height,
+		Time:            block.Time,
+		Hash:            block.Hash().String(),
+		LastBlockHash:   block.LastBlockID.Hash.String(),
+		AppHash:         block.AppHash.String(),
+		ProposerAddress: block.ProposerAddress.String(),
+		TxCount:         len(block.Txs),
+	}
+}
+
+// verifyBlockLinks checks b against the indexed blocks right before and after it: b's LastBlockHash
+// must be the hash stored for height-1, and the LastBlockHash stored for height+1 must be b's hash.
+// Neighbours that are not indexed, or were indexed before hashes were recorded, are not checked.
+func verifyBlockLinks(tx *database.Database, b types2.Block) error {
+	neighbours, err := tx.ListBlocksByHeight(b.Height-1, b.Height+1)
+	if err != nil {
+		return fmt.Errorf("failed to load neighbouring blocks: %w", err)
+	}
+
+	for _, n := range neighbours {
+		if n.Height == b.Height-1 && n.Hash != "" && n.Hash != b.LastBlockHash {
+			return fmt.Errorf("%w: block %d links to previous block %s but %s is indexed at height %d", errBlockMismatch, b.Height, b.LastBlockHash, n.Hash, n.Height)
+		}
+		if n.Height == b.Height+1 && n.LastBlockHash != "" && n.LastBlockHash != b.Hash {
+			return fmt.Errorf("%w: block %d has hash %s but the indexed block %d links to %s", errBlockMismatch, b.Height, b.Hash, n.Height, n.LastBlockHash)
+		}
+	}
+
+	return nil
+}
+
+// halt stops the current run because of a condition retrying cannot fix. Run returns err once it
+// has shut down.
+func (i *Indexer) halt(err error) {
+	i.runMu.Lock()
+	defer i.runMu.Unlock()
+
+	if i.haltErr == nil {
+		i.haltErr = err
+	}
+	if i.cancel != nil {
+		i.cancel()
+	}
+}
+
+// halted returns the error the indexer was halted with, nil if it was not.
+func (i *Indexer) halted() error {
+	i.runMu.Lock()
+	defer i.runMu.Unlock()
+	return i.haltErr
+}
@@ -0,0 +1,228 @@
+package indexer
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JackalLabs/jindexer/database"
+	types2 "github.com/JackalLabs/jindexer/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"github.com/jackalLabs/canine-chain/v5/x/storage/types"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	disputeKindAttestation = "attestation"
+	disputeKindReport      = "report"
+)
+
+// needsStorageParams reports whether a block has messages whose outcome depends on the storage module
+// params: attestations and reports pass a form once AttestMinToPass attesters completed it.
+func needsStorageParams(stats []types2.MessageTypeStat) bool {
+	for _, stat := range stats {
+		switch stat.TypeURL {
+		case sdk.MsgTypeURL(&types.MsgAttest{}), sdk.MsgTypeURL(&types.MsgReport{}):
+			return true
+		}
+	}
+	return false
+}
+
+// storageParamsAtHeight returns the storage module params the messages of height were executed with,
+// read over gRPC from the state committed at the previous height. Historical heights need a gRPC
+// endpoint that has not pruned them, endpoints known to have pruned the height are tried last.
+func (i *Indexer) storageParamsAtHeight(ctx context.Context, height int64) (*types.Params, error) {
+	queryHeight := height - 1
+	if queryHeight < 1 {
+		queryHeight = height
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatInt(queryHeight, 10))
+
+	var res *types.QueryParamsResponse
+	err := i.grpc.call(ctx, queryHeight, func(conn *grpc.ClientConn) error {
+		var err error
+		res, err = types.NewQueryClient(conn).Params(ctx, &types.QueryParams{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res.Params, nil
+}
+
+// processDisputeMessage records attestation and report messages, opening a dispute when a form is
+// requested and counting completed attestations until the form passes.
+func (i *Indexer) processDisputeMessage(tx *database.Database, msg sdk.Msg, block types2.Block, transaction types2.Transaction, response []byte, storageParams *types.Params) error {
+	event := types2.DisputeEvent{
+		Success:       transaction.Code == 0,
+		BlockId:       block.ID,
+		TransactionId: transaction.ID,
+	}
+	if !event.Success {
+		event.Error = transaction.Log
+	}
+
+	var kind string
+	switch m := msg.(type) {
+	case *types.MsgRequestAttestationForm:
+		// a prover asks other providers to vouch for its deal
+		kind = disputeKindAttestation
+		event.Action = "request_attestation"
+		event.Creator = m.Creator
+		event.Prover = m.Creator
+		event.Merkle = hex.EncodeToString(m.Merkle)
+		event.Owner = m.Owner
+		event.Start = m.Start
+	case *types.MsgAttest:
+		kind = disputeKindAttestation
+		event.Action = "attest"
+		event.Creator = m.Creator
+		event.Prover = m.Prover
+		event.Merkle = hex.EncodeToString(m.Merkle)
+		event.Owner = m.Owner
+		event.Start = m.Start
+	case *types.MsgRequestReportForm:
+		kind = disputeKindReport
+		event.Action = "request_report"
+		event.Creator = m.Creator
+		event.Prover = m.Prover
+		event.Merkle = hex.EncodeToString(m.Merkle)
+		event.Owner = m.Owner
+		event.Start = m.Start
+	case *types.MsgReport:
+		kind = disputeKindReport
+		event.Action = "report"
+		event.Creator = m.Creator
+		event.Prover = m.Prover
+		event.Merkle = hex.EncodeToString(m.Merkle)
+		event.Owner = m.Owner
+		event.Start = m.Start
+	default:
+		return nil
+	}
+
+	log.Info().Str("action", event.Action).Str("prover", event.Prover).Str("merkle", event.Merkle).Msg("processing dispute message")
+
+	if event.Action == "request_attestation" || event.Action == "request_report" {
+		return i.openDispute(tx, kind, event, block, response)
+	}
+	return i.attestDispute(tx, kind, event, block, storageParams)
+}
+
+// openDispute stores a requested form. Form requests report their outcome in the msg response
+// rather than failing the tx, so the response decides whether a form was actually opened.
+func (i *Indexer) openDispute(tx *database.Database, kind string, event types2.DisputeEvent, block types2.Block, response []byte) error {
+	var providers []string
+	if event.Success {
+		var success bool
+		var errorMessage string
+		if kind == disputeKindAttestation {
+			var res types.MsgRequestAttestationFormResponse
+			if err := res.Unmarshal(response); err != nil {
+				return err
+			}
+			success, errorMessage, providers = res.Success, res.Error, res.Providers
+		} else {
+			var res types.MsgRequestReportFormResponse
+			if err := res.Unmarshal(response); err != nil {
+				return err
+			}
+			success, errorMessage, providers = res.Success, res.Error, res.Providers
+		}
+		event.Success = success
+		event.Error = errorMessage
+	}
+
+	if event.Success {
+		dispute := types2.Dispute{
+			Kind:         kind,
+			Prover:       event.Prover,
+			Merkle:       event.Merkle,
+			Owner:        event.Owner,
+			Start:        event.Start,
+			OpenedHeight: block.Height,
+			OpenedAt:     block.Time,
+			Requester:    event.Creator,
+			Attesters:    strings.Join(providers, ","),
+			Status:       "open",
+		}
+		err := tx.OpenDispute(&dispute)
+		if err != nil {
+			return err
+		}
+		event.DisputeId = dispute.ID
+	}
+
+	return tx.SaveDisputeEvent(&event)
+}
+
+// attestDispute counts an attestation towards its open form and resolves the form once enough
+// of the selected providers have completed it, per the AttestMinToPass in effect for the block.
+// Without the params the attestation is still counted but the form is left open.
+func (i *Indexer) attestDispute(tx *database.Database, kind string, event types2.DisputeEvent, block types2.Block, storageParams *types.Params) error {
+	dispute, err := tx.GetOpenDispute(kind, event.Prover, event.Merkle, event.Owner, event.Start)
+	if err != nil {
+		return err
+	}
+	if dispute == nil {
+		return tx.SaveDisputeEvent(&event)
+	}
+	event.DisputeId = dispute.ID
+
+	// MsgAttest never fails the tx, only attesters selected for the form count
+	if event.Success && !containsAddress(dispute.Attesters, event.Creator) {
+		event.Success = false
+		event.Error = "not selected to attest to this form"
+	}
+
+	err = tx.SaveDisputeEvent(&event)
+	if err != nil {
+		return err
+	}
+	if !event.Success {
+		return nil
+	}
+
+	attestations, err := tx.CountDisputeAttesters(dispute.ID, event.Action)
+	if err != nil {
+		return err
+	}
+	dispute.Attestations = int(attestations)
+
+	if storageParams == nil {
+		log.Warn().Str("prover", dispute.Prover).Str("merkle", dispute.Merkle).Int64("height", block.Height).Msg("storage params unavailable, leaving dispute open")
+	} else if attestations >= storageParams.AttestMinToPass {
+		resolvedAt := block.Time
+		dispute.ResolvedHeight = block.Height
+		dispute.ResolvedAt = &resolvedAt
+		if kind == disputeKindAttestation {
+			dispute.Status = "attested"
+		} else {
+			dispute.Status = "reported"
+		}
+		log.Info().Str("prover", dispute.Prover).Str("merkle", dispute.Merkle).Str("status", dispute.Status).Msg("dispute resolved")
+	}
+
+	return tx.UpdateDispute(dispute)
+}
+
+// containsAddress reports whether address is in a comma separated address list.
+func containsAddress(list string, address string) bool {
+	for _, a := range strings.Split(list, ",") {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,498 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	"github.com/rs/zerolog/log"
+	"github.com/tendermint/tendermint/rpc/client/http"
+	"google.golang.org/grpc"
+)
+
+const (
+	// endpointMaxFailures is how many consecutive failures take an endpoint out of rotation.
+	endpointMaxFailures = 3
+	// endpointCooldown is how long an endpoint stays out of rotation after failing repeatedly.
+	endpointCooldown = time.Minute
+	// endpointSampleWeight is the weight of the newest call in the latency and error rate averages.
+	endpointSampleWeight = 0.2
+	// endpointErrorPenalty scales how much the error rate worsens an endpoint's latency score.
+	endpointErrorPenalty = 10
+)
+
+// errNoEndpoints is returned when a network has no endpoint serving its chain.
+var errNoEndpoints = errors.New("no RPC endpoint available")
+
+// endpointHealth tracks how an RPC or gRPC endpoint has been performing.
+type endpointHealth struct {
+	url  string
+	kind string // RPC or gRPC, for logs
+
+	mu sync.Mutex
+	// latency and errorRate are moving averages over recent calls
+	latency   time.Duration
+	errorRate float64
+	// failures counts consecutive failed calls, reaching endpointMaxFailures starts a cooldown
+	failures      int
+	cooldownUntil time.Time
+	// wrongChain is set when the node serves a different network than the indexer
+	wrongChain bool
+}
+
+// record updates the endpoint's health with the outcome of a call.
+func (e *endpointHealth) record(latency time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	sample := 0.0
+	if err != nil {
+		sample = 1
+	}
+	e.errorRate += endpointSampleWeight * (sample - e.errorRate)
+
+	if err != nil {
+		e.failures++
+		if e.failures >= endpointMaxFailures {
+			e.cooldownUntil = time.Now().Add(endpointCooldown)
+			log.Warn().Err(err).Str("endpoint", e.url).Dur("cooldown", endpointCooldown).Msgf("%s endpoint keeps failing, taking it out of rotation", e.kind)
+		}
+		return
+	}
+
+	e.failures = 0
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency += time.Duration(endpointSampleWeight * float64(latency-e.latency))
+	}
+}
+
+// healthLocked returns whether the endpoint is in rotation and its latency score, lower being better.
+// The caller must hold mu.
+func (e *endpointHealth) healthLocked(now time.Time) (healthy bool, score float64) {
+	latency := e.latency
+	if latency == 0 {
+		// unmeasured endpoints get a neutral latency so they are tried eventually
+		latency = time.Second
+	}
+
+	return !e.wrongChain && now.After(e.cooldownUntil), latency.Seconds() * (1 + endpointErrorPenalty*e.errorRate)
+}
+
+// setChain records which chain the endpoint serves, taking it out of rotation if it is not chainID.
+// The caller must hold mu.
+func (e *endpointHealth) setChainLocked(network string, chainID string) {
+	if network != chainID && !e.wrongChain {
+		log.Error().Str("endpoint", e.url).Str("chain_id", network).Str("expected", chainID).Msgf("%s endpoint serves another chain, ignoring it", e.kind)
+	}
+	e.wrongChain = network != chainID
+}
+
+// isWrongChain reports whether the endpoint serves another network.
+func (e *endpointHealth) isWrongChain() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.wrongChain
+}
+
+// rpcEndpoint is an RPC node the indexer can fetch from, along with what is known about its health.
+type rpcEndpoint struct {
+	endpointHealth
+	client *http.HTTP
+
+	// the range of heights the node reported in /status, 0 until it has been checked
+	earliestHeight int64
+	latestHeight   int64
+}
+
+// endpointState is a consistent snapshot of an endpoint's health used to rank it.
+type endpointState struct {
+	endpoint *rpcEndpoint
+	healthy  bool
+	hasBlock bool // the node has not pruned the height
+	behind   bool // the node has not caught up to the height yet
+	score    float64
+}
+
+// state ranks the endpoint for fetching height, 0 meaning the chain head. Lower scores are better.
+func (e *rpcEndpoint) state(height int64, now time.Time) endpointState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	healthy, score := e.healthLocked(now)
+	return endpointState{
+		endpoint: e,
+		healthy:  healthy,
+		hasBlock: height == 0 || e.earliestHeight <= height,
+		behind:   height != 0 && e.latestHeight != 0 && e.latestHeight < height,
+		score:    score,
+	}
+}
+
+// endpointTier groups endpoints by whether they can serve a height, lower tiers are tried first.
+func endpointTier(s endpointState) int {
+	switch {
+	case s.healthy && s.hasBlock && !s.behind:
+		return 0
+	case s.healthy && s.hasBlock:
+		return 1
+	case s.healthy:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// endpointPool routes RPC calls to the best endpoint of a network.
+type endpointPool struct {
+	endpoints []*rpcEndpoint
+}
+
+// newEndpointPool creates clients for the given RPC endpoints without contacting them.
+func newEndpointPool(urls []string) (*endpointPool, error) {
+	if len(urls) == 0 {
+		return nil, errNoEndpoints
+	}
+
+	pool := endpointPool{}
+	for _, url := range urls {
+		rpcClient, err := client.NewClientFromNode(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RPC endpoint %s: %w", url, err)
+		}
+		pool.endpoints = append(pool.endpoints, &rpcEndpoint{endpointHealth: endpointHealth{url: url, kind: "RPC"}, client: rpcClient})
+	}
+
+	return &pool, nil
+}
+
+// ranked returns the endpoints in the order they should be tried for height. Healthy endpoints that
+// still have the block come first, so heights the others have pruned go to archive nodes, and
+// endpoints that are behind the height, unhealthy or missing the block are only used as a last resort.
+func (p *endpointPool) ranked(height int64) []*rpcEndpoint {
+	now := time.Now()
+	states := make([]endpointState, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		state := e.state(height, now)
+		if state.endpoint.wrongChain {
+			continue
+		}
+		states = append(states, state)
+	}
+
+	sort.SliceStable(states, func(a, b int) bool {
+		if endpointTier(states[a]) != endpointTier(states[b]) {
+			return endpointTier(states[a]) < endpointTier(states[b])
+		}
+		return states[a].score < states[b].score
+	})
+
+	ranked := make([]*rpcEndpoint, len(states))
+	for idx, s := range states {
+		ranked[idx] = s.endpoint
+	}
+	return ranked
+}
+
+// best returns the endpoint calls for height should go to.
+func (p *endpointPool) best(height int64) (*rpcEndpoint, error) {
+	ranked := p.ranked(height)
+	if len(ranked) == 0 {
+		return nil, errNoEndpoints
+	}
+	return ranked[0], nil
+}
+
+// call runs fn against the best endpoint for height, failing over to the next one when it errors.
+// Every attempt is timed and recorded in the endpoint's health. The last error is returned.
+func (p *endpointPool) call(ctx context.Context, height int64, fn func(c *http.HTTP) error) error {
+	ranked := p.ranked(height)
+	if len(ranked) == 0 {
+		return errNoEndpoints
+	}
+
+	var err error
+	for _, e := range ranked {
+		start := time.Now()
+		err = fn(e.client)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		e.record(time.Since(start), err)
+		if err == nil {
+			return nil
+		}
+
+		if len(ranked) > 1 {
+			log.Warn().Err(err).Str("endpoint", e.url).Int64("height", height).Msg("RPC call failed, trying the next endpoint")
+		}
+	}
+	return err
+}
+
+// check polls /status of every endpoint, refreshing the heights they serve. Endpoints serving another
+// chain than chainID are taken out of rotation. It returns the highest height any endpoint reported.
+func (p *endpointPool) check(ctx context.Context, chainID string) int64 {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func(e *rpcEndpoint) {
+			defer wg.Done()
+
+			start := time.Now()
+			status, err := e.client.Status(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			e.record(time.Since(start), err)
+			if err != nil {
+				log.Warn().Err(err).Str("endpoint", e.url).Msg("failed to check RPC endpoint status")
+				return
+			}
+
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.earliestHeight = status.SyncInfo.EarliestBlockHeight
+			e.latestHeight = status.SyncInfo.LatestBlockHeight
+			e.setChainLocked(status.NodeInfo.Network, chainID)
+		}(e)
+	}
+	wg.Wait()
+
+	var latest int64
+	for _, e := range p.endpoints {
+		e.mu.Lock()
+		if !e.wrongChain && e.latestHeight > latest {
+			latest = e.latestHeight
+		}
+		e.mu.Unlock()
+	}
+	return latest
+}
+
+// close stops the websocket of every endpoint that opened one.
+func (p *endpointPool) close() {
+	for _, e := range p.endpoints {
+		if !e.client.IsRunning() {
+			continue
+		}
+		err := e.client.Stop()
+		if err != nil {
+			log.Warn().Err(err).Str("endpoint", e.url).Msg("failed to close RPC websocket")
+		}
+	}
+}
+
+// chainID returns the chain served by the first endpoint that answers /status.
+func (p *endpointPool) chainID(ctx context.Context) (string, error) {
+	var chainID string
+	err := p.call(ctx, 0, func(c *http.HTTP) error {
+		status, err := c.Status(ctx)
+		if err != nil {
+			return err
+		}
+		chainID = status.NodeInfo.Network
+		return nil
+	})
+	return chainID, err
+}
+
+// watchEndpoints periodically refreshes the health and height range of every RPC and gRPC endpoint.
+func (i *Indexer) watchEndpoints(ctx context.Context) {
+	ticker := time.NewTicker(i.config.EndpointCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			i.observeNetworkHeight(i.rpc.check(ctx, i.chainID))
+			i.grpc.check(ctx, i.chainID)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// grpcEndpoint is a gRPC node the indexer queries, along with what is known about its health.
+type grpcEndpoint struct {
+	endpointHealth
+	conn *grpc.ClientConn
+
+	// latestHeight is the node's latest block, 0 until it has been checked
+	latestHeight int64
+	// prunedHeight is the highest height the node failed to load the state of, it does not serve
+	// queries at or below it. gRPC has no equivalent of the earliest height in /status, so it is
+	// learned from the queries that failed.
+	prunedHeight int64
+}
+
+// state ranks the endpoint for a query at height, 0 meaning the latest state. Lower scores are better.
+func (e *grpcEndpoint) state(height int64, now time.Time) endpointState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	healthy, score := e.healthLocked(now)
+	return endpointState{
+		healthy:  healthy,
+		hasBlock: height == 0 || e.prunedHeight < height,
+		behind:   height != 0 && e.latestHeight != 0 && e.latestHeight < height,
+		score:    score,
+	}
+}
+
+// grpcPool routes gRPC queries to the best endpoint of a network, ranked like the RPC endpoints.
+type grpcPool struct {
+	endpoints []*grpcEndpoint
+}
+
+// newGrpcPool dials every gRPC endpoint. Connections are established lazily by gRPC.
+func newGrpcPool(urls []string) (*grpcPool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no gRPC endpoint configured")
+	}
+
+	pool := grpcPool{}
+	for _, url := range urls {
+		conn, err := CreateGrpcConnection(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gRPC endpoint %s: %w", url, err)
+		}
+		pool.endpoints = append(pool.endpoints, &grpcEndpoint{endpointHealth: endpointHealth{url: url, kind: "gRPC"}, conn: conn})
+	}
+
+	return &pool, nil
+}
+
+// ranked returns the endpoints in the order they should be tried for a query at height, with the
+// same tiers as endpointPool.ranked: endpoints known to have pruned the height go last.
+func (p *grpcPool) ranked(height int64) []*grpcEndpoint {
+	now := time.Now()
+	type ranking struct {
+		endpoint *grpcEndpoint
+		state    endpointState
+	}
+	rankings := make([]ranking, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		state := e.state(height, now)
+		if e.isWrongChain() {
+			continue
+		}
+		rankings = append(rankings, ranking{endpoint: e, state: state})
+	}
+
+	sort.SliceStable(rankings, func(a, b int) bool {
+		if endpointTier(rankings[a].state) != endpointTier(rankings[b].state) {
+			return endpointTier(rankings[a].state) < endpointTier(rankings[b].state)
+		}
+		return rankings[a].state.score < rankings[b].state.score
+	})
+
+	ranked := make([]*grpcEndpoint, len(rankings))
+	for idx, r := range rankings {
+		ranked[idx] = r.endpoint
+	}
+	return ranked
+}
+
+// call runs fn against the best endpoint for a query at height, 0 meaning the latest state, failing
+// over to the next one when it errors. fn must pin the query to height itself. An endpoint that cannot
+// load the state at height is remembered as pruned rather than unhealthy. The last error is returned.
+func (p *grpcPool) call(ctx context.Context, height int64, fn func(conn *grpc.ClientConn) error) error {
+	ranked := p.ranked(height)
+	if len(ranked) == 0 {
+		return errNoEndpoints
+	}
+
+	var err error
+	for _, e := range ranked {
+		start := time.Now()
+		err = fn(e.conn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if height != 0 && isPrunedHeightError(err) {
+			e.markPruned(height)
+		} else {
+			e.record(time.Since(start), err)
+		}
+		if err == nil {
+			return nil
+		}
+
+		log.Warn().Err(err).Str("endpoint", e.url).Int64("height", height).Msg("gRPC call failed")
+	}
+	return err
+}
+
+// markPruned records that the endpoint cannot serve queries at height.
+func (e *grpcEndpoint) markPruned(height int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if height > e.prunedHeight {
+		e.prunedHeight = height
+	}
+}
+
+// isPrunedHeightError reports whether a query failed because the node no longer has the state it
+// was pinned to.
+func isPrunedHeightError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "failed to load state at height")
+}
+
+// check asks every gRPC endpoint for its network and latest height. Endpoints serving another chain
+// than chainID are taken out of rotation.
+func (p *grpcPool) check(ctx context.Context, chainID string) {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func(e *grpcEndpoint) {
+			defer wg.Done()
+
+			start := time.Now()
+			service := tmservice.NewServiceClient(e.conn)
+			nodeInfo, err := service.GetNodeInfo(ctx, &tmservice.GetNodeInfoRequest{})
+			var latest *tmservice.GetLatestBlockResponse
+			if err == nil {
+				latest, err = service.GetLatestBlock(ctx, &tmservice.GetLatestBlockRequest{})
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			e.record(time.Since(start), err)
+			if err != nil {
+				log.Warn().Err(err).Str("endpoint", e.url).Msg("failed to check gRPC endpoint status")
+				return
+			}
+
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if latest.Block != nil {
+				e.latestHeight = latest.Block.Header.Height
+			}
+			e.setChainLocked(nodeInfo.DefaultNodeInfo.Network, chainID)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// close closes every gRPC connection, returning the first error.
+func (p *grpcPool) close() error {
+	var firstErr error
+	for _, e := range p.endpoints {
+		err := e.conn.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
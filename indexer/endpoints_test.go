@@ -0,0 +1,57 @@
+package indexer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGrpcPoolRoutesPrunedHeightsToArchive(t *testing.T) {
+	pruned := &grpcEndpoint{endpointHealth: endpointHealth{url: "pruned", kind: "gRPC"}}
+	archive := &grpcEndpoint{endpointHealth: endpointHealth{url: "archive", kind: "gRPC"}}
+	pool := grpcPool{endpoints: []*grpcEndpoint{pruned, archive}}
+
+	// the pruned node is the faster one, so it serves recent state
+	pruned.record(10*time.Millisecond, nil)
+	archive.record(500*time.Millisecond, nil)
+	if ranked := pool.ranked(0); ranked[0] != pruned {
+		t.Fatalf("latest state went to %s, want pruned", ranked[0].url)
+	}
+
+	// once it failed to load an old height, that height and older ones go to the archive node
+	err := errors.New("rpc error: code = InvalidArgument desc = failed to load state at height 100; version does not exist (latest height: 5000): invalid request")
+	if !isPrunedHeightError(err) {
+		t.Fatal("pruned height error not recognized")
+	}
+	pruned.markPruned(100)
+
+	for _, height := range []int64{50, 100} {
+		if ranked := pool.ranked(height); ranked[0] != archive {
+			t.Fatalf("height %d went to %s, want archive", height, ranked[0].url)
+		}
+	}
+	if ranked := pool.ranked(4000); ranked[0] != pruned {
+		t.Fatalf("height 4000 went to %s, want pruned", ranked[0].url)
+	}
+
+	// a pruned height is not a failure of the endpoint
+	if pruned.failures != 0 || pruned.errorRate != 0 {
+		t.Fatalf("pruned height counted as a failure: %d failures, error rate %f", pruned.failures, pruned.errorRate)
+	}
+}
+
+func TestGrpcPoolSkipsFailingEndpoints(t *testing.T) {
+	flaky := &grpcEndpoint{endpointHealth: endpointHealth{url: "flaky", kind: "gRPC"}}
+	steady := &grpcEndpoint{endpointHealth: endpointHealth{url: "steady", kind: "gRPC"}}
+	pool := grpcPool{endpoints: []*grpcEndpoint{flaky, steady}}
+
+	flaky.record(10*time.Millisecond, nil)
+	steady.record(100*time.Millisecond, nil)
+	for n := 0; n < endpointMaxFailures; n++ {
+		flaky.record(10*time.Millisecond, errors.New("unavailable"))
+	}
+
+	if ranked := pool.ranked(0); ranked[0] != steady {
+		t.Fatalf("query went to %s, want steady", ranked[0].url)
+	}
+}
@@ -0,0 +1,60 @@
+package indexer
+
+import (
+	"encoding/hex"
+
+	"github.com/JackalLabs/jindexer/database"
+	types2 "github.com/JackalLabs/jindexer/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/jackalLabs/canine-chain/v5/x/storage/types"
+	"github.com/rs/zerolog/log"
+)
+
+func (i *Indexer) processPostFile(tx *database.Database, msg sdk.Msg, block types2.Block, transaction types2.Transaction) error {
+	msgPostFile, ok := msg.(*types.MsgPostFile)
+	if !ok {
+		return nil
+	}
+
+	// a failed MsgPostFile never opened a deal on chain
+	if transaction.Code != 0 {
+		return nil
+	}
+
+	log.Info().Msg("processing MsgPostFile")
+
+	// canine-chain keys the file by the height it was posted at
+	file := types2.File{
+		Merkle:        hex.EncodeToString(msgPostFile.Merkle),
+		Owner:         msgPostFile.Creator,
+		Start:         block.Height,
+		FileSize:      msgPostFile.FileSize,
+		ProofInterval: msgPostFile.ProofInterval,
+		ProofType:     msgPostFile.ProofType,
+		MaxProofs:     msgPostFile.MaxProofs,
+		Expires:       msgPostFile.Expires,
+		Note:          msgPostFile.Note,
+		BlockId:       block.ID,
+		TransactionId: transaction.ID,
+	}
+
+	return tx.SaveFile(&file)
+}
+
+func (i *Indexer) processDeleteFile(tx *database.Database, msg sdk.Msg, block types2.Block, transaction types2.Transaction) error {
+	msgDeleteFile, ok := msg.(*types.MsgDeleteFile)
+	if !ok {
+		return nil
+	}
+
+	// the file is still on chain if the delete failed
+	if transaction.Code != 0 {
+		return nil
+	}
+
+	log.Info().Msg("processing MsgDeleteFile")
+
+	merkle := hex.EncodeToString(msgDeleteFile.Merkle)
+	return tx.MarkFileDeleted(merkle, msgDeleteFile.Creator, msgDeleteFile.Start, block, transaction.ID)
+}
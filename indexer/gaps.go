@@ -0,0 +1,103 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JackalLabs/jindexer/database"
+	"github.com/rs/zerolog/log"
+)
+
+// ScanGaps returns the holes in the indexed height sequence. When from or to are 0 they default to
+// the lowest and highest heights currently in the database.
+func (i *Indexer) ScanGaps(from, to int64) ([]database.HeightRange, error) {
+	var err error
+	if from == 0 {
+		from, err = i.database.GetLowestBlockHeight()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if to == 0 {
+		to, err = i.database.GetMostRecentBlockHeight()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if to < from {
+		return nil, nil
+	}
+
+	return i.database.ListBlockGaps(from, to)
+}
+
+// RepairGaps re-indexes every height in the given ranges through the normal indexBlock path.
+// Heights that still fail after their retries are recorded as failed heights.
+// It returns the number of heights that were indexed successfully.
+func (i *Indexer) RepairGaps(ctx context.Context, gaps []database.HeightRange) int64 {
+	var repaired int64
+	for _, gap := range gaps {
+		log.Info().Int64("from", gap.Start).Int64("to", gap.End).Msg("Backfilling gap")
+
+		for height := gap.Start; height <= gap.End; height++ {
+			if ctx.Err() != nil {
+				return repaired
+			}
+
+			err := i.indexBlockWithRetry(ctx, height)
+			if ctx.Err() != nil {
+				return repaired
+			}
+			if errors.Is(err, errBlockMismatch) {
+				i.halt(err)
+				return repaired
+			}
+			if err != nil {
+				i.recordFailedHeight(height, err)
+				continue
+			}
+			repaired++
+		}
+	}
+	return repaired
+}
+
+// backfillGaps scans for gaps once and repairs whatever it finds.
+func (i *Indexer) backfillGaps(ctx context.Context) {
+	gaps, err := i.ScanGaps(0, 0)
+	if err != nil {
+		log.Err(err).Msg("failed to scan for gaps")
+		return
+	}
+	if len(gaps) == 0 {
+		log.Debug().Msg("no gaps found in indexed heights")
+		return
+	}
+
+	var missing int64
+	for _, gap := range gaps {
+		missing += gap.Size()
+	}
+	log.Warn().Int("gaps", len(gaps)).Int64("missing_heights", missing).Msg("Found gaps in indexed heights")
+
+	repaired := i.RepairGaps(ctx, gaps)
+	log.Info().Int64("repaired", repaired).Int64("missing_heights", missing).Msg("Finished backfilling gaps")
+}
+
+// watchGaps backfills gaps at startup and then on every GapScanInterval tick.
+func (i *Indexer) watchGaps(ctx context.Context) {
+	i.backfillGaps(ctx)
+
+	ticker := time.NewTicker(i.config.GapScanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			i.backfillGaps(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package indexer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/JackalLabs/jindexer/database"
+	types2 "github.com/JackalLabs/jindexer/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/jackalLabs/canine-chain/v5/x/storage/types"
+)
+
+// MessageContext is the block and transaction a message was carried in, handed to its MessageHandler.
+type MessageContext struct {
+	// DB is the database transaction the block is committed in.
+	DB          *database.Database
+	Block       types2.Block
+	Transaction types2.Transaction
+	// MsgIndex is the position of the message within its transaction. Messages unwrapped from a
+	// wrapper such as authz MsgExec share the index of the wrapper.
+	MsgIndex int
+	// Signer is the address that signed the outer message. For messages executed through an authz
+	// grant it is the grantee, while the message itself names the granter as its creator.
+	Signer string
+	// Response is the encoded Msg response, nil for failed transactions.
+	Response []byte
+	// StorageParams are the storage module params in effect for the block. They are only fetched
+	// for blocks with messages whose outcome depends on them (see needsStorageParams), and are nil
+	// otherwise or when no endpoint could serve them.
+	StorageParams *types.Params
+}
+
+// MessageHandler indexes the messages of the type URLs it is registered for.
+type MessageHandler interface {
+	// Name identifies the handler when enabling or disabling it through the configuration.
+	Name() string
+	// TypeURLs are the message type URLs (e.g. "/canine_chain.storage.MsgPostProof") the handler processes.
+	TypeURLs() []string
+	// Handle stores a single message. Returning an error rolls back the whole block.
+	Handle(ctx MessageContext, msg sdk.Msg) error
+}
+
+type funcHandler struct {
+	name     string
+	typeURLs []string
+	handle   func(ctx MessageContext, msg sdk.Msg) error
+}
+
+func (h funcHandler) Name() string       { return h.name }
+func (h funcHandler) TypeURLs() []string { return h.typeURLs }
+func (h funcHandler) Handle(ctx MessageContext, msg sdk.Msg) error {
+	return h.handle(ctx, msg)
+}
+
+// NewMessageHandler builds a MessageHandler out of a function processing the messages of the given type URLs.
+func NewMessageHandler(name string, typeURLs []string, handle func(ctx MessageContext, msg sdk.Msg) error) MessageHandler {
+	return funcHandler{
+		name:     name,
+		typeURLs: typeURLs,
+		handle:   handle,
+	}
+}
+
+// Registry maps message type URLs to the handler processing them.
+type Registry struct {
+	handlers map[string]MessageHandler
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]MessageHandler)}
+}
+
+// Register adds a handler for all of its type URLs. A type URL can only be handled by one handler.
+func (r *Registry) Register(h MessageHandler) error {
+	for _, typeURL := range h.TypeURLs() {
+		if existing, ok := r.handlers[typeURL]; ok {
+			return fmt.Errorf("%s is already handled by %s", typeURL, existing.Name())
+		}
+	}
+
+	for _, typeURL := range h.TypeURLs() {
+		r.handlers[typeURL] = h
+	}
+	return nil
+}
+
+// Handler returns the handler registered for a type URL, or nil if there is none.
+func (r *Registry) Handler(typeURL string) MessageHandler {
+	return r.handlers[typeURL]
+}
+
+// Names returns the names of the registered handlers, sorted.
+func (r *Registry) Names() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, h := range r.handlers {
+		if !seen[h.Name()] {
+			seen[h.Name()] = true
+			names = append(names, h.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterHandler adds a handler on top of the built-in ones. It must be called before Start.
+func (i *Indexer) RegisterHandler(h MessageHandler) error {
+	return i.handlers.Register(h)
+}
+
+// builtinHandlers returns the handlers shipped with the indexer.
+func (i *Indexer) builtinHandlers() []MessageHandler {
+	return []MessageHandler{
+		NewMessageHandler("proofs", []string{
+			sdk.MsgTypeURL(&types.MsgPostProof{}),
+			sdk.MsgTypeURL(&types.MsgPostProofFor{}),
+		}, func(ctx MessageContext, msg sdk.Msg) error {
+			return i.processPostProof(ctx.DB, msg, ctx.Block, ctx.Transaction, ctx.MsgIndex, ctx.Signer, ctx.Response)
+		}),
+		NewMessageHandler("files", []string{
+			sdk.MsgTypeURL(&types.MsgPostFile{}),
+			sdk.MsgTypeURL(&types.MsgDeleteFile{}),
+		}, func(ctx MessageContext, msg sdk.Msg) error {
+			if _, ok := msg.(*types.MsgDeleteFile); ok {
+				return i.processDeleteFile(ctx.DB, msg, ctx.Block, ctx.Transaction)
+			}
+			return i.processPostFile(ctx.DB, msg, ctx.Block, ctx.Transaction)
+		}),
+		NewMessageHandler("providers", []string{
+			sdk.MsgTypeURL(&types.MsgInitProvider{}),
+			sdk.MsgTypeURL(&types.MsgSetProviderIP{}),
+			sdk.MsgTypeURL(&types.MsgSetProviderTotalSpace{}),
+			sdk.MsgTypeURL(&types.MsgSetProviderKeybase{}),
+			sdk.MsgTypeURL(&types.MsgShutdownProvider{}),
+		}, func(ctx MessageContext, msg sdk.Msg) error {
+			return i.processProviderMessage(ctx.DB, msg, ctx.Block, ctx.Transaction)
+		}),
+		NewMessageHandler("storage_plans", []string{
+			sdk.MsgTypeURL(&types.MsgBuyStorage{}),
+		}, func(ctx MessageContext, msg sdk.Msg) error {
+			return i.processBuyStorage(ctx.DB, msg, ctx.Block, ctx.Transaction)
+		}),
+		NewMessageHandler("disputes", []string{
+			sdk.MsgTypeURL(&types.MsgRequestAttestationForm{}),
+			sdk.MsgTypeURL(&types.MsgAttest{}),
+			sdk.MsgTypeURL(&types.MsgRequestReportForm{}),
+			sdk.MsgTypeURL(&types.MsgReport{}),
+		}, func(ctx MessageContext, msg sdk.Msg) error {
+			return i.processDisputeMessage(ctx.DB, msg, ctx.Block, ctx.Transaction, ctx.Response, ctx.StorageParams)
+		}),
+	}
+}
+
+// registerBuiltinHandlers registers the built-in handlers selected by the configuration. When
+// EnabledHandlers is set only those are registered, DisabledHandlers are always left out.
+func (i *Indexer) registerBuiltinHandlers() error {
+	builtin := make(map[string]bool)
+	for _, h := range i.builtinHandlers() {
+		builtin[h.Name()] = true
+	}
+
+	enabled := make(map[string]bool)
+	for _, name := range i.config.EnabledHandlers {
+		if !builtin[name] {
+			return fmt.Errorf("unknown message handler %q", name)
+		}
+		enabled[name] = true
+	}
+	disabled := make(map[string]bool)
+	for _, name := range i.config.DisabledHandlers {
+		if !builtin[name] {
+			return fmt.Errorf("unknown message handler %q", name)
+		}
+		disabled[name] = true
+	}
+
+	for _, h := range i.builtinHandlers() {
+		if len(enabled) > 0 && !enabled[h.Name()] {
+			continue
+		}
+		if disabled[h.Name()] {
+			continue
+		}
+		err := i.handlers.Register(h)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
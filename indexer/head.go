@@ -0,0 +1,189 @@
+package indexer
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tendermint/tendermint/rpc/client/http"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// FollowModeWebsocket follows the chain head through NewBlock events, polling only as a fallback.
+	FollowModeWebsocket = "websocket"
+	// FollowModePoll follows the chain head by polling ABCIInfo.
+	FollowModePoll = "poll"
+
+	headSubscriber = "jindexer"
+	// headEventTimeout is how long the subscription may stay silent before it is considered dropped.
+	headEventTimeout = time.Minute
+	// resubscribeDelay is how long to wait before trying to subscribe again after a failure.
+	resubscribeDelay = 10 * time.Second
+)
+
+var newBlockQuery = tmtypes.QueryForEvent(tmtypes.EventNewBlock).String()
+
+// waitForNetworkHeight blocks until the chain has produced the given height.
+// While the NewBlock subscription is healthy it waits for events instead of calling the RPC,
+// otherwise it polls ABCIInfo every PollInterval.
+func (i *Indexer) waitForNetworkHeight(ctx context.Context, height int64) error {
+	for {
+		if i.networkHeight.Load() >= height {
+			return nil
+		}
+
+		if i.subscribed.Load() {
+			changed := i.headChanged()
+			if i.networkHeight.Load() >= height {
+				return nil
+			}
+
+			select {
+			case <-changed:
+				continue
+			case <-time.After(headEventTimeout):
+				// fall through and poll once in case the subscription silently stalled
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		var networkHeight int64
+		err := i.rpc.call(ctx, 0, func(c *http.HTTP) error {
+			abciInfo, err := c.ABCIInfo(ctx)
+			if err != nil {
+				return err
+			}
+			networkHeight = abciInfo.Response.LastBlockHeight
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		i.observeNetworkHeight(networkHeight)
+		if networkHeight >= height {
+			return nil
+		}
+
+		if i.subscribed.Load() {
+			continue
+		}
+
+		log.Info().Int64("current_height", height).Int64("network_height", networkHeight).Msg("network is behind us, waiting for more blocks")
+		select {
+		case <-time.After(i.config.PollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// observeNetworkHeight records a chain height seen on the network, never moving backwards,
+// and wakes up everything waiting for the head to move.
+func (i *Indexer) observeNetworkHeight(height int64) {
+	for {
+		known := i.networkHeight.Load()
+		if height <= known {
+			return
+		}
+		if i.networkHeight.CompareAndSwap(known, height) {
+			break
+		}
+	}
+
+	i.headMu.Lock()
+	close(i.headCh)
+	i.headCh = make(chan struct{})
+	i.headMu.Unlock()
+}
+
+// headChanged returns a channel that is closed the next time the network height advances.
+func (i *Indexer) headChanged() <-chan struct{} {
+	i.headMu.Lock()
+	defer i.headMu.Unlock()
+	return i.headCh
+}
+
+// followHead keeps a NewBlock subscription open on the RPC websocket and feeds every new height
+// to the pipeline. Whenever the subscription fails or goes quiet the fetchers fall back to polling
+// until it is re-established; heights produced in between are reconciled through ABCIInfo.
+func (i *Indexer) followHead(ctx context.Context) {
+	defer i.subscribed.Store(false)
+
+	for ctx.Err() == nil {
+		err := i.followHeadOnce(ctx)
+		i.subscribed.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Warn().Err(err).Dur("retry_in", resubscribeDelay).Msg("NewBlock subscription lost, falling back to polling")
+		select {
+		case <-time.After(resubscribeDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// followHeadOnce subscribes to NewBlock events on the best RPC endpoint and consumes them until the
+// subscription drops. Resubscribing picks the best endpoint again, so a failing node is left behind.
+func (i *Indexer) followHeadOnce(ctx context.Context) error {
+	endpoint, err := i.rpc.best(0)
+	if err != nil {
+		return err
+	}
+	rpcClient := endpoint.client
+
+	if !rpcClient.IsRunning() {
+		err := rpcClient.Start()
+		if err != nil {
+			endpoint.record(0, err)
+			return err
+		}
+	}
+
+	events, err := rpcClient.Subscribe(ctx, headSubscriber, newBlockQuery, 16)
+	if err != nil {
+		endpoint.record(0, err)
+		return err
+	}
+	defer func() {
+		// the subscription is dropped anyway if the websocket is gone
+		_ = rpcClient.Unsubscribe(context.Background(), headSubscriber, newBlockQuery)
+	}()
+
+	// Reconcile heights produced while we were not subscribed. The dispatcher walks every height,
+	// so moving the known head forward is enough for the fetchers to pick the missed ones up.
+	before := i.networkHeight.Load()
+	abciInfo, err := rpcClient.ABCIInfo(ctx)
+	if err != nil {
+		endpoint.record(0, err)
+		return err
+	}
+	i.observeNetworkHeight(abciInfo.Response.LastBlockHeight)
+	if before > 0 && abciInfo.Response.LastBlockHeight > before {
+		log.Info().Int64("from", before+1).Int64("to", abciInfo.Response.LastBlockHeight).Msg("Reconciled heights missed while not subscribed")
+	}
+
+	i.subscribed.Store(true)
+	log.Info().Str("endpoint", endpoint.url).Str("query", newBlockQuery).Msg("Following chain head over websocket")
+
+	for {
+		select {
+		case event := <-events:
+			data, ok := event.Data.(tmtypes.EventDataNewBlock)
+			if !ok || data.Block == nil {
+				continue
+			}
+			i.observeNetworkHeight(data.Block.Height)
+		case <-time.After(headEventTimeout):
+			endpoint.record(0, context.DeadlineExceeded)
+			return context.DeadlineExceeded
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -2,192 +2,575 @@ package indexer
 
 import (
 	"context"
-	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/JackalLabs/jindexer/database"
 	types2 "github.com/JackalLabs/jindexer/types"
 
-	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	canine "github.com/jackalLabs/canine-chain/v5/app"
 	"github.com/jackalLabs/canine-chain/v5/app/params"
 	"github.com/jackalLabs/canine-chain/v5/x/storage/types"
 
-	"github.com/cosmos/cosmos-sdk/client"
 	"github.com/rs/zerolog/log"
 	"github.com/tendermint/tendermint/rpc/client/http"
-	"google.golang.org/grpc"
+	coretypes "github.com/tendermint/tendermint/rpc/core/types"
 )
 
+// Config holds the tunables of an Indexer.
+type Config struct {
+	// Workers is the number of goroutines fetching blocks from the RPC concurrently.
+	Workers int
+	// PrefetchDepth is how many heights the fetchers may run ahead of the committer.
+	PrefetchDepth int
+	// MaxRetries is how many times a height is retried before it is recorded as failed.
+	MaxRetries int
+	// RetryBaseDelay is the first backoff delay, doubled on every following attempt.
+	RetryBaseDelay time.Duration
+	// RetryMaxDelay caps the backoff delay between attempts.
+	RetryMaxDelay time.Duration
+	// FailedHeightsInterval is how often the recorded failed heights are worked through again.
+	FailedHeightsInterval time.Duration
+	// GapScanInterval is how often the blocks table is scanned for missing heights. 0 disables the scan.
+	GapScanInterval time.Duration
+	// FollowMode is how the chain head is followed, FollowModeWebsocket or FollowModePoll.
+	FollowMode string
+	// PollInterval is how long to wait between ABCIInfo calls while polling for new blocks.
+	PollInterval time.Duration
+	// EndpointCheckInterval is how often the status of every RPC endpoint is checked to score it.
+	EndpointCheckInterval time.Duration
+	// EnabledHandlers restricts the built-in message handlers to the named ones. Empty enables them all.
+	EnabledHandlers []string
+	// DisabledHandlers are built-in message handlers that are never run.
+	DisabledHandlers []string
+	// Cursor names the cursor an unbounded run advances with every committed height and resumes from.
+	Cursor string
+	// TrustedHeight and TrustedHash (hex) enable light client verification of every fetched block,
+	// starting from the trusted header. A TrustedHeight of 0 trusts the RPC.
+	TrustedHeight int64
+	TrustedHash   string
+	// TrustingPeriod is how long a verified header is trusted, it must be below the unbonding period.
+	TrustingPeriod time.Duration
+}
+
+// DefaultConfig returns the configuration used when nothing is overridden.
+func DefaultConfig() Config {
+	return Config{
+		Workers:               4,
+		PrefetchDepth:         32,
+		MaxRetries:            5,
+		RetryBaseDelay:        time.Second,
+		RetryMaxDelay:         time.Minute,
+		FailedHeightsInterval: 5 * time.Minute,
+		GapScanInterval:       10 * time.Minute,
+		FollowMode:            FollowModeWebsocket,
+		PollInterval:          6 * time.Second,
+		EndpointCheckInterval: 30 * time.Second,
+		Cursor:                DefaultCursor,
+		TrustingPeriod:        14 * 24 * time.Hour,
+	}
+}
+
+// DefaultCursor is the cursor of the live indexer.
+const DefaultCursor = "live"
+
+// errAlreadyRunning is returned by Run when the indexer is already running.
+var errAlreadyRunning = errors.New("indexer is already running")
+
 type Indexer struct {
-	running       bool
+	// cancel and done belong to the current Run, both are nil while the indexer is not running.
+	// haltErr is why the run was halted, see halt.
+	runMu   sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+	haltErr error
+
+	chainID       string
 	startHeight   int64
 	endHeight     int64
 	currentHeight int64
-	grpcClient    *grpc.ClientConn
-	rpcClient     *http.HTTP
+	grpc          *grpcPool
+	rpc           *endpointPool
 	codec         params.EncodingConfig
 	database      *database.Database
+	config        Config
+	handlers      *Registry
+	light         *lightVerifier // nil unless light client verification is enabled
+
+	// networkHeight is the latest chain height seen, headCh is closed and replaced whenever it advances
+	networkHeight atomic.Int64
+	subscribed    atomic.Bool
+	headMu        sync.Mutex
+	headCh        chan struct{}
+
+	// committedCount and failedCount count the heights the pipeline indexed and the heights it recorded
+	// as failed, for progress reporting
+	committedCount atomic.Int64
+	failedCount    atomic.Int64
 }
 
-func NewIndexer(rpcEndpoint string, grpcEndpoint string, codec params.EncodingConfig, db *database.Database, startHeight int64, endHeight int64) (*Indexer, error) {
-	rpcClient, err := client.NewClientFromNode(rpcEndpoint)
+// NewIndexer creates an indexer fetching from the given RPC and gRPC endpoints of a single network.
+// Calls are routed to the healthiest endpoint that has the requested height, failing over to the others.
+func NewIndexer(rpcEndpoints []string, grpcEndpoints []string, codec params.EncodingConfig, db *database.Database, startHeight int64, endHeight int64, config Config) (*Indexer, error) {
+	rpcPool, err := newEndpointPool(rpcEndpoints)
 	if err != nil {
 		return nil, err
 	}
 
-	grpcClient, err := CreateGrpcConnection(grpcEndpoint)
+	grpcPool, err := newGrpcPool(grpcEndpoints)
 	if err != nil {
 		return nil, err
 	}
 
+	// the database is scoped to the network the RPC serves, so several networks can share it
+	chainID := db.ChainID()
+	if chainID == "" {
+		chainID, err = rpcPool.chainID(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to query node status: %w", err)
+		}
+		db = db.ForChain(chainID)
+	}
+	rpcPool.check(context.Background(), chainID)
+	if _, err := rpcPool.best(0); err != nil {
+		return nil, fmt.Errorf("no RPC endpoint serves chain %s: %w", chainID, err)
+	}
+
+	if config.EndpointCheckInterval <= 0 {
+		config.EndpointCheckInterval = DefaultConfig().EndpointCheckInterval
+	}
+
+	var verifier *lightVerifier
+	if config.TrustedHeight > 0 {
+		if config.TrustingPeriod <= 0 {
+			config.TrustingPeriod = DefaultConfig().TrustingPeriod
+		}
+		verifier, err = newLightVerifier(context.Background(), chainID, rpcEndpoints, config.TrustedHeight, config.TrustedHash, config.TrustingPeriod)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Str("chain_id", chainID).Int64("trusted_height", config.TrustedHeight).Msg("verifying fetched blocks with a light client")
+	}
+
+	if config.Cursor == "" {
+		config.Cursor = DefaultCursor
+	}
+
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.PrefetchDepth < config.Workers {
+		config.PrefetchDepth = config.Workers
+	}
+
 	i := Indexer{
-		running:       false,
+		chainID:       chainID,
 		startHeight:   startHeight,
 		endHeight:     endHeight,
 		currentHeight: startHeight,
-		grpcClient:    grpcClient,
-		rpcClient:     rpcClient,
+		grpc:          grpcPool,
+		rpc:           rpcPool,
 		codec:         codec,
 		database:      db,
+		config:        config,
+		handlers:      NewRegistry(),
+		light:         verifier,
+		headCh:        make(chan struct{}),
 	}
+
+	err = i.registerBuiltinHandlers()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("chain_id", chainID).Strs("handlers", i.handlers.Names()).Msg("registered message handlers")
+
 	return &i, nil
 }
 
+// ChainID returns the chain ID of the network being indexed.
+func (i *Indexer) ChainID() string {
+	return i.chainID
+}
+
+// Start runs the indexer until the end height is reached or Stop is called, see Run.
 func (i *Indexer) Start() {
-	i.running = true
-	ctx := context.Background()
-	for i.running {
-		if i.currentHeight >= i.endHeight && i.endHeight > 0 { // stop when end height is reached if end height is not 0
-			i.running = false
-			return
+	err := i.Run(context.Background())
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Err(err).Str("chain_id", i.chainID).Msg("indexer stopped")
+	}
+}
+
+// Run runs the indexing pipeline until the end height is reached (or until ctx is cancelled if the
+// end height is 0). Blocks are fetched and decoded concurrently but always committed in strict height order.
+// A bounded run only works through its range: it does not follow the head, retry failed heights or
+// scan for gaps, and logs its progress instead.
+//
+// Cancelling ctx or calling Stop shuts the indexer down: the block being committed finishes or rolls
+// back as a whole, nothing after it is committed, and Run returns once every goroutine it started
+// has exited. It returns ctx's error if it was stopped before a bounded range was done, or the
+// error it was halted with if a fetched block did not match the indexed chain.
+func (i *Indexer) Run(ctx context.Context) error {
+	i.runMu.Lock()
+	if i.done != nil {
+		i.runMu.Unlock()
+		return errAlreadyRunning
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	i.cancel, i.done = cancel, done
+	i.haltErr = nil
+	i.runMu.Unlock()
+
+	defer func() {
+		cancel()
+		i.runMu.Lock()
+		i.cancel, i.done = nil, nil
+		i.runMu.Unlock()
+		close(done)
+	}()
+
+	bounded := i.endHeight > 0
+
+	var background sync.WaitGroup
+	goBackground := func(fn func(ctx context.Context)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			fn(ctx)
+		}()
+	}
+
+	log.Info().
+		Str("chain_id", i.chainID).
+		Int64("start_height", i.startHeight).
+		Int64("end_height", i.endHeight).
+		Int("workers", i.config.Workers).
+		Int("prefetch_depth", i.config.PrefetchDepth).
+		Msg("Starting indexer pipeline")
+
+	goBackground(i.watchEndpoints)
+	if bounded {
+		goBackground(func(ctx context.Context) {
+			i.reportProgress(ctx, i.endHeight-i.startHeight)
+		})
+	} else {
+		if i.config.FollowMode == FollowModeWebsocket {
+			goBackground(i.followHead)
+		}
+		goBackground(i.retryFailedHeights)
+		if i.config.GapScanInterval > 0 {
+			goBackground(i.watchGaps)
 		}
+	}
+
+	started := time.Now()
+	i.runPipeline(ctx)
 
-		i.indexBlock(ctx, i.currentHeight)
-		i.currentHeight++
+	stopped := ctx.Err()
+	cancel()
+	background.Wait()
+
+	if err := i.halted(); err != nil {
+		log.Error().Err(err).Str("chain_id", i.chainID).Int64("height", i.currentHeight).Msg("Indexer halted")
+		return err
 	}
+	if stopped != nil {
+		log.Info().Str("chain_id", i.chainID).Int64("next_height", i.currentHeight).Msg("Indexer stopped")
+		return stopped
+	}
+
+	if bounded {
+		log.Info().
+			Str("chain_id", i.chainID).
+			Int64("from", i.startHeight).
+			Int64("to", i.endHeight-1).
+			Int64("indexed", i.committedCount.Load()).
+			Int64("failed", i.failedCount.Load()).
+			Dur("took", time.Since(started).Round(time.Second)).
+			Msg("Finished indexing range")
+	}
+
+	return nil
 }
 
-func (i *Indexer) indexBlock(ctx context.Context, height int64) {
-	log.Info().Int64("height", height).Msg("Indexing block...")
+// Stop shuts a running indexer down and waits for Run to return. It does nothing if the indexer is
+// not running.
+func (i *Indexer) Stop() {
+	i.runMu.Lock()
+	cancel, done := i.cancel, i.done
+	i.runMu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Close releases the RPC and gRPC connections of the indexer. It must not be running anymore.
+func (i *Indexer) Close() error {
+	i.rpc.close()
+	return i.grpc.close()
+}
+
+// indexBlock fetches and commits a single height outside of the pipeline, leaving every cursor alone.
+func (i *Indexer) indexBlock(ctx context.Context, height int64) error {
+	fetched := i.fetchBlock(ctx, height)
+	return i.commitBlock(fetched, "")
+}
+
+// fetchBlock pulls a block and its results from the RPC and decodes its transactions in parallel.
+// It never touches the database besides checking whether the height is already indexed.
+func (i *Indexer) fetchBlock(ctx context.Context, height int64) *fetchedBlock {
+	fetched := &fetchedBlock{height: height}
 
 	alreadyIndexed, err := i.database.BlockExistsByHeight(height)
 	if err != nil {
-		log.Err(err).Msg("failed to check if block exists")
-		return
+		log.Err(err).Int64("height", height).Msg("failed to check if block exists")
+		fetched.err = err
+		return fetched
 	}
 	if alreadyIndexed {
-		log.Info().Int64("height", height).Msg("Block already indexed")
-		return
+		fetched.skipped = true
+		return fetched
 	}
 
-	var networkHeight int64 = 0
+	err = i.waitForNetworkHeight(ctx, height)
+	if err != nil {
+		log.Err(err).Msg("failed to get abci info")
+		fetched.err = err
+		return fetched
+	}
 
-	for networkHeight < height || networkHeight == 0 {
-		if networkHeight < height && networkHeight > 0 {
-			log.Info().Int64("current_height", height).Int64("network_height", networkHeight).Msg("network is behind us, waiting for more blocks")
-			time.Sleep(time.Second * 6)
-		}
-		abciInfo, err := i.rpcClient.ABCIInfo(ctx)
+	var blockInfo *coretypes.ResultBlock
+	var blockResults *coretypes.ResultBlockResults
+	err = i.rpc.call(ctx, height, func(c *http.HTTP) error {
+		blockInfo, err = c.Block(ctx, &height)
+		return err
+	})
+	if err != nil {
+		log.Err(err).Int64("height", height).Msg("failed to get block info")
+		fetched.err = err
+		return fetched
+	}
+
+	err = i.rpc.call(ctx, height, func(c *http.HTTP) error {
+		blockResults, err = c.BlockResults(ctx, &height)
+		return err
+	})
+	if err != nil {
+		log.Err(err).Int64("height", height).Msg("failed to get block results")
+		fetched.err = err
+		return fetched
+	}
+	if len(blockResults.TxsResults) != len(blockInfo.Block.Txs) {
+		fetched.err = fmt.Errorf("block %d has %d txs but %d tx results", height, len(blockInfo.Block.Txs), len(blockResults.TxsResults))
+		return fetched
+	}
+
+	if i.light != nil {
+		// the results are committed to by the next header
+		err = i.waitForNetworkHeight(ctx, height+1)
 		if err != nil {
-			log.Err(err).Msg("failed to get abci info")
-			return
+			fetched.err = err
+			return fetched
 		}
 
-		networkHeight = abciInfo.Response.LastBlockHeight
+		err = i.light.verify(ctx, blockInfo.Block, blockResults.TxsResults)
+		if err != nil {
+			log.Err(err).Int64("height", height).Msg("rejected block")
+			fetched.err = err
+			return fetched
+		}
 	}
 
-	blockInfo, err := i.rpcClient.Block(ctx, &height)
-	if err != nil {
-		log.Err(err).Msg("failed to get block info")
-		return
+	fetched.block = blockInfo.Block
+	fetched.txs = i.decodeTxs(blockInfo.Block.Txs, blockResults.TxsResults)
+	fetched.stats = i.messageTypeStats(fetched.txs)
+
+	// the committer never calls out to the network, whatever the messages need is fetched here.
+	// Pruned endpoints cannot serve old params, the block is indexed without them and the forms
+	// it attests to are left open rather than losing the rest of the block.
+	if needsStorageParams(fetched.stats) {
+		fetched.storageParams, err = i.storageParamsAtHeight(ctx, height)
+		if err != nil {
+			log.Warn().Err(err).Int64("height", height).Msg("failed to get storage params, dispute forms of this block will not be resolved")
+		}
 	}
 
-	block := blockInfo.Block
+	return fetched
+}
+
+// commitBlock writes a fetched block and the messages it contains to the database. A non-empty cursor
+// is moved to the block's height in the same transaction.
+func (i *Indexer) commitBlock(fetched *fetchedBlock, cursor string) error {
+	height := fetched.height
+	log.Info().Int64("height", height).Msg("Indexing block...")
 
-	b := types2.Block{
-		Time:   block.Time,
-		Height: height,
+	if fetched.err != nil {
+		return fetched.err
 	}
-	err = i.database.SaveBlock(&b)
-	if err != nil {
-		log.Err(err).Msg("failed to save block info")
-		return
+	if fetched.skipped {
+		log.Info().Int64("height", height).Msg("Block already indexed")
+		if cursor != "" {
+			return i.database.SaveCursor(cursor, height, "")
+		}
+		return nil
 	}
 
-	txs := block.Txs
-	log.Info().Int("TX_Count", len(txs)).Msg("Indexed block.")
+	// Everything for a height is written in one transaction, so a height is either
+	// fully indexed (block and all of its messages) or not indexed at all.
+	alreadyIndexed := false
+	err := i.database.Transaction(func(tx *database.Database) error {
+		// gap repair, failed-height retries and backfill can commit a height between the fetch and
+		// now, a height committed by someone else is skipped instead of failing on the unique key
+		exists, err := tx.BlockExistsByHeight(height)
+		if err != nil {
+			return err
+		}
+		if exists {
+			alreadyIndexed = true
+			if cursor != "" {
+				return tx.SaveCursor(cursor, height, fetched.block.Hash().String())
+			}
+			return nil
+		}
 
-	for _, txBytes := range txs {
-		txHash := hex.EncodeToString(txBytes.Hash())
+		// never index a block that does not link up with the ones already indexed around it
+		b := newBlockRecord(fetched.block)
+		err = verifyBlockLinks(tx, b)
+		if err != nil {
+			return err
+		}
 
-		// Decode the transaction bytes into a Tx
-		tx, err := i.codec.TxConfig.TxDecoder()(txBytes)
+		err = tx.SaveBlock(&b)
 		if err != nil {
-			log.Err(err).Str("tx", txHash).Msg("failed to decode TX")
-			continue
+			return fmt.Errorf("failed to save block info: %w", err)
 		}
 
-		// Extract messages from the transaction
-		msgs := tx.GetMsgs()
-		for _, msg := range msgs {
-			err := i.processMessage(msg, b)
+		for idx, t := range fetched.txs {
+			transaction := i.buildTransaction(t, idx, b)
+			err := tx.SaveTransaction(&transaction)
 			if err != nil {
-				log.Err(err).Msg("could not process message")
+				return fmt.Errorf("failed to save tx %s: %w", t.hash, err)
+			}
+
+			if t.err != nil {
+				log.Err(t.err).Str("tx", t.hash).Msg("failed to decode TX")
 				continue
 			}
+
+			if !t.succeeded() {
+				log.Info().Str("tx", t.hash).Uint32("code", t.result.Code).Msg("Tx failed on chain, flagging its messages")
+			}
+
+			// Extract messages from the transaction along with their responses
+			msgs := t.tx.GetMsgs()
+			responses := t.msgResponses()
+			for msgIndex, msg := range msgs {
+				var response []byte
+				if msgIndex < len(responses) {
+					response = responses[msgIndex]
+				}
+
+				err := i.processMessage(tx, msg, b, transaction, msgIndex, response, fetched.storageParams)
+				if err != nil {
+					return fmt.Errorf("could not process message in tx %s: %w", t.hash, err)
+				}
+			}
+
+			log.Info().Str("tx", t.hash).Msg("Tx parsed")
 		}
 
-		log.Info().Str("tx", txHash).Msg("Tx parsed")
-	}
-}
+		err = tx.SaveMessageTypeStats(b, fetched.stats)
+		if err != nil {
+			return fmt.Errorf("failed to save message type stats: %w", err)
+		}
+
+		if cursor != "" {
+			err = tx.SaveCursor(cursor, height, fetched.block.Hash().String())
+			if err != nil {
+				return fmt.Errorf("failed to move cursor %s: %w", cursor, err)
+			}
+		}
 
-func (i *Indexer) processMessage(msg sdk.Msg, block types2.Block) error {
-	// Get the type URL from the message by packing it into an Any
-	msgAny, err := codectypes.NewAnyWithValue(msg)
+		return nil
+	})
 	if err != nil {
-		log.Err(err).Msg("failed to pack message into Any")
+		log.Err(err).Int64("height", height).Msg("failed to commit block, rolled back")
 		return err
 	}
-	messageType := msgAny.TypeUrl
-
-	log.Info().Str("message_type", messageType).Msg("processing message")
-
-	switch messageType {
-	case "/canine_chain.storage.MsgPostProof":
-		err = i.processPostProof(msg, block)
-	default:
-		log.Warn().Str("message_type_url", messageType).Msg("could not process message")
+	if alreadyIndexed {
+		log.Info().Int64("height", height).Msg("Block already indexed")
 		return nil
 	}
 
-	return err
+	log.Info().Str("chain_id", i.chainID).Int64("height", height).Int("TX_Count", len(fetched.txs)).Msg("Indexed block.")
+
+	return nil
 }
 
-func (i *Indexer) processPostProof(msg sdk.Msg, block types2.Block) error {
-	// Cast the message to the specific type
-	msgPostProof, ok := msg.(*types.MsgPostProof)
-	if !ok {
-		return nil
+// processMessage stores a single message carried by transaction at position msgIndex, with response
+// holding the encoded Msg response (nil for failed transactions). The message is handed to the
+// handler registered for its type URL, messages without a handler are skipped.
+// Messages from failed transactions are stored flagged with the transaction's code and log.
+func (i *Indexer) processMessage(tx *database.Database, msg sdk.Msg, block types2.Block, transaction types2.Transaction, msgIndex int, response []byte, storageParams *types.Params) error {
+	// the signer of the outer message is who actually submitted it, e.g. the grantee of an authz MsgExec
+	var signer string
+	if signers := msg.GetSigners(); len(signers) > 0 {
+		signer, _ = sdk.Bech32ifyAddressBytes(canine.Bech32PrefixAccAddr, signers[0])
 	}
 
-	// Process the message
-	log.Info().Msg("processing MsgPostProof")
-	_ = msgPostProof // Use msgPostProof as needed
+	return i.dispatchMessage(MessageContext{
+		DB:            tx,
+		Block:         block,
+		Transaction:   transaction,
+		MsgIndex:      msgIndex,
+		Signer:        signer,
+		Response:      response,
+		StorageParams: storageParams,
+	}, msg)
+}
+
+// dispatchMessage hands msg to its handler, unwrapping wrapper messages recursively so every inner
+// message reaches its own handler with the context of the outer message.
+func (i *Indexer) dispatchMessage(ctx MessageContext, msg sdk.Msg) error {
+	messageType := sdk.MsgTypeURL(msg)
+
+	if inner, responses, ok := i.wrappedMessages(msg, ctx.Response); ok {
+		log.Info().Str("message_type", messageType).Int("inner_messages", len(inner)).Msg("unwrapping message")
+
+		for idx, innerMsg := range inner {
+			if innerMsg == nil {
+				continue
+			}
 
-	merkle := hex.EncodeToString(msgPostProof.Merkle)
-	prover := msgPostProof.Creator
+			innerCtx := ctx
+			innerCtx.Response = nil
+			if idx < len(responses) {
+				innerCtx.Response = responses[idx]
+			}
 
-	postProof := types2.PostProof{
-		Merkle: merkle,
-		Prover: prover,
-		Block:  block,
+			err := i.dispatchMessage(innerCtx, innerMsg)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	err := i.database.SavePostProof(&postProof)
-	if err != nil {
-		return err
+	handler := i.handlers.Handler(messageType)
+	if handler == nil {
+		log.Debug().Str("message_type_url", messageType).Msg("no handler for message")
+		return nil
 	}
 
-	return nil
+	log.Info().Str("message_type", messageType).Str("handler", handler.Name()).Msg("processing message")
+
+	return handler.Handle(ctx, msg)
 }
@@ -0,0 +1,120 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	dbm "github.com/cometbft/cometbft-db"
+	"github.com/rs/zerolog/log"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/light"
+	"github.com/tendermint/tendermint/light/provider"
+	lighthttp "github.com/tendermint/tendermint/light/provider/http"
+	lightdb "github.com/tendermint/tendermint/light/store/db"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+// errLightVerification means a fetched block or its results do not match the headers verified by the
+// light client. The data is rejected, the height is retried and eventually recorded as failed.
+var errLightVerification = errors.New("block failed light client verification")
+
+// lightVerifier checks fetched blocks against headers verified with Tendermint's light client logic,
+// so an RPC cannot feed the indexer blocks the validators never signed. The light client starts from
+// a trusted height and hash and verifies every other header through the validator sets, skipping
+// ahead where it can. Heights below the trusted height are verified backwards through the hash chain,
+// one header at a time.
+type lightVerifier struct {
+	// the light client is not safe for concurrent verification
+	mu     sync.Mutex
+	client *light.Client
+}
+
+// newLightVerifier creates a light client for chainID trusting the header at trustedHeight with the
+// hex encoded trustedHash. The first RPC endpoint is the primary, the others witness its headers.
+// With a single endpoint it witnesses itself, which verifies signatures but cannot detect a fork.
+// Verified headers are kept in memory, so the light client starts over from the trusted header on restart.
+func newLightVerifier(ctx context.Context, chainID string, rpcEndpoints []string, trustedHeight int64, trustedHash string, trustingPeriod time.Duration) (*lightVerifier, error) {
+	hash, err := hex.DecodeString(trustedHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted hash %q: %w", trustedHash, err)
+	}
+
+	providers := make([]provider.Provider, 0, len(rpcEndpoints))
+	for _, url := range rpcEndpoints {
+		p, err := lighthttp.New(chainID, url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid light client provider %s: %w", url, err)
+		}
+		providers = append(providers, p)
+	}
+	if len(providers) == 0 {
+		return nil, errNoEndpoints
+	}
+
+	witnesses := providers[1:]
+	if len(witnesses) == 0 {
+		log.Warn().Str("chain_id", chainID).Msg("only one RPC endpoint, the light client cannot cross-check headers against a witness")
+		witnesses = providers[:1]
+	}
+
+	client, err := light.NewClient(
+		ctx,
+		chainID,
+		light.TrustOptions{
+			Period: trustingPeriod,
+			Height: trustedHeight,
+			Hash:   hash,
+		},
+		providers[0],
+		witnesses,
+		lightdb.New(dbm.NewMemDB(), chainID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start light client: %w", err)
+	}
+
+	return &lightVerifier{client: client}, nil
+}
+
+// verifiedHeader returns the light client verified header at height.
+func (v *lightVerifier) verifiedHeader(ctx context.Context, height int64) (*tmtypes.LightBlock, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.client.VerifyLightBlockAtHeight(ctx, height, time.Now())
+}
+
+// verify checks a fetched block and its DeliverTx results. The block must hash to the verified header
+// at its height and its transactions must match the header's data hash. The results must hash to the
+// LastResultsHash of the next header, so the next height has to exist already. Only the deterministic
+// parts of a result (code, data and gas) are covered, logs and events are not.
+func (v *lightVerifier) verify(ctx context.Context, block *tmtypes.Block, results []*abci.ResponseDeliverTx) error {
+	err := block.ValidateBasic()
+	if err != nil {
+		return fmt.Errorf("%w: block %d is malformed: %s", errLightVerification, block.Height, err)
+	}
+
+	header, err := v.verifiedHeader(ctx, block.Height)
+	if err != nil {
+		return fmt.Errorf("failed to verify header %d: %w", block.Height, err)
+	}
+	if !bytes.Equal(header.Hash(), block.Hash()) {
+		return fmt.Errorf("%w: block %d has hash %s but the verified header has %s", errLightVerification, block.Height, block.Hash(), header.Hash())
+	}
+
+	next, err := v.verifiedHeader(ctx, block.Height+1)
+	if err != nil {
+		return fmt.Errorf("failed to verify header %d: %w", block.Height+1, err)
+	}
+	resultsHash := tmtypes.NewResults(results).Hash()
+	if !bytes.Equal(next.LastResultsHash, resultsHash) {
+		return fmt.Errorf("%w: results of block %d hash to %X but the verified header %d has %s", errLightVerification, block.Height, resultsHash, next.Height, next.LastResultsHash)
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package indexer
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmlog "github.com/tendermint/tendermint/libs/log"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	rpcserver "github.com/tendermint/tendermint/rpc/jsonrpc/server"
+	rpctypes "github.com/tendermint/tendermint/rpc/jsonrpc/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+const testChainID = "test-chain"
+
+// testChain is a chain of blocks signed by a generated validator set, served over an RPC.
+type testChain struct {
+	vals    *tmtypes.ValidatorSet
+	blocks  map[int64]*tmtypes.Block
+	commits map[int64]*tmtypes.Commit
+	results map[int64][]*abci.ResponseDeliverTx
+	latest  int64
+}
+
+// newTestChain builds heights 1 to latest, each block carrying one transaction, linked to the previous
+// block and committed by every validator.
+func newTestChain(t *testing.T, latest int64) *testChain {
+	t.Helper()
+
+	privVals := make([]tmtypes.PrivValidator, 4)
+	validators := make([]*tmtypes.Validator, len(privVals))
+	for i := range privVals {
+		pv := tmtypes.NewMockPV()
+		pubKey, err := pv.GetPubKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		privVals[i] = pv
+		validators[i] = tmtypes.NewValidator(pubKey, 10)
+	}
+	vals := tmtypes.NewValidatorSet(validators)
+
+	// MakeCommit signs with the validator at each index, so line the signers up with the set's order
+	signers := make([]tmtypes.PrivValidator, len(privVals))
+	for _, pv := range privVals {
+		pubKey, err := pv.GetPubKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx, _ := vals.GetByAddress(pubKey.Address())
+		signers[idx] = pv
+	}
+	privVals = signers
+
+	chain := &testChain{
+		vals:    vals,
+		blocks:  make(map[int64]*tmtypes.Block),
+		commits: make(map[int64]*tmtypes.Commit),
+		results: make(map[int64][]*abci.ResponseDeliverTx),
+		latest:  latest,
+	}
+
+	start := time.Now().Add(-time.Hour)
+	// the first block has an empty last commit, like a chain starting from genesis
+	lastCommit := &tmtypes.Commit{}
+	var lastBlockID tmtypes.BlockID
+	var lastResults []*abci.ResponseDeliverTx
+	for height := int64(1); height <= latest; height++ {
+		txs := []tmtypes.Tx{[]byte(fmt.Sprintf("tx-%d", height))}
+		block := tmtypes.MakeBlock(height, txs, lastCommit, nil)
+		block.ChainID = testChainID
+		block.Time = start.Add(time.Duration(height) * time.Second)
+		block.LastBlockID = lastBlockID
+		block.ValidatorsHash = vals.Hash()
+		block.NextValidatorsHash = vals.Hash()
+		block.ProposerAddress = vals.Proposer.Address
+		block.LastResultsHash = tmtypes.NewResults(lastResults).Hash()
+
+		partSet := block.MakePartSet(tmtypes.BlockPartSizeBytes)
+		blockID := tmtypes.BlockID{Hash: block.Hash(), PartSetHeader: partSet.Header()}
+
+		voteSet := tmtypes.NewVoteSet(testChainID, height, 0, tmproto.PrecommitType, vals)
+		commit, err := tmtypes.MakeCommit(blockID, height, 0, voteSet, privVals, block.Time.Add(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results := []*abci.ResponseDeliverTx{{Code: 0, Data: []byte(fmt.Sprintf("result-%d", height)), GasUsed: 100}}
+
+		chain.blocks[height] = block
+		chain.commits[height] = commit
+		chain.results[height] = results
+
+		lastCommit = commit
+		lastBlockID = blockID
+		lastResults = results
+	}
+
+	return chain
+}
+
+// serve starts an RPC serving the chain's commits and validator sets, the endpoints the light client uses.
+func (c *testChain) serve(t *testing.T) string {
+	t.Helper()
+
+	commit := func(_ *rpctypes.Context, height *int64) (*ctypes.ResultCommit, error) {
+		h := c.latest
+		if height != nil {
+			h = *height
+		}
+		block, ok := c.blocks[h]
+		if !ok {
+			return nil, fmt.Errorf("height %d is not available", h)
+		}
+		return ctypes.NewResultCommit(&block.Header, c.commits[h], true), nil
+	}
+
+	validators := func(_ *rpctypes.Context, height *int64, _, _ *int) (*ctypes.ResultValidators, error) {
+		h := c.latest
+		if height != nil {
+			h = *height
+		}
+		if _, ok := c.blocks[h]; !ok {
+			return nil, fmt.Errorf("height %d is not available", h)
+		}
+		return &ctypes.ResultValidators{
+			BlockHeight: h,
+			Validators:  c.vals.Validators,
+			Count:       len(c.vals.Validators),
+			Total:       len(c.vals.Validators),
+		}, nil
+	}
+
+	mux := http.NewServeMux()
+	rpcserver.RegisterRPCFuncs(mux, map[string]*rpcserver.RPCFunc{
+		"commit":     rpcserver.NewRPCFunc(commit, "height"),
+		"validators": rpcserver.NewRPCFunc(validators, "height,page,per_page"),
+	}, tmlog.NewNopLogger())
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// verifier starts a light client trusting the chain's first header.
+func (c *testChain) verifier(t *testing.T) *lightVerifier {
+	t.Helper()
+
+	url := c.serve(t)
+	v, err := newLightVerifier(context.Background(), testChainID, []string{url}, 1, hex.EncodeToString(c.blocks[1].Hash()), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestLightVerifierAcceptsSignedBlock(t *testing.T) {
+	chain := newTestChain(t, 5)
+	v := chain.verifier(t)
+
+	err := v.verify(context.Background(), chain.blocks[3], chain.results[3])
+	if err != nil {
+		t.Fatalf("verify: %s", err)
+	}
+}
+
+func TestLightVerifierRejectsTamperedBlock(t *testing.T) {
+	chain := newTestChain(t, 5)
+	v := chain.verifier(t)
+
+	// a block the validators never signed hashes differently from the verified header
+	block := chain.blocks[3]
+	tampered := tmtypes.MakeBlock(block.Height, block.Txs, block.LastCommit, nil)
+	tampered.Header = block.Header
+	tampered.AppHash = []byte("forged app hash")
+
+	err := v.verify(context.Background(), tampered, chain.results[3])
+	if !errors.Is(err, errLightVerification) {
+		t.Fatalf("verify: got %v, want %v", err, errLightVerification)
+	}
+}
+
+func TestLightVerifierRejectsTamperedResults(t *testing.T) {
+	chain := newTestChain(t, 5)
+	v := chain.verifier(t)
+
+	// results that do not hash to the LastResultsHash of the next verified header
+	results := []*abci.ResponseDeliverTx{{Code: 1, Data: []byte("result-3"), GasUsed: 100}}
+
+	err := v.verify(context.Background(), chain.blocks[3], results)
+	if !errors.Is(err, errLightVerification) {
+		t.Fatalf("verify: got %v, want %v", err, errLightVerification)
+	}
+}
+
+func TestLightVerifierRejectsTamperedLastResultsHash(t *testing.T) {
+	chain := newTestChain(t, 5)
+
+	// an RPC vouching for forged results has to serve a next header the validators never signed
+	forged := tmtypes.NewResults([]*abci.ResponseDeliverTx{{Code: 1}}).Hash()
+	chain.blocks[4].LastResultsHash = forged
+	v := chain.verifier(t)
+
+	err := v.verify(context.Background(), chain.blocks[3], []*abci.ResponseDeliverTx{{Code: 1}})
+	if err == nil {
+		t.Fatal("verify accepted results against an unsigned header")
+	}
+}
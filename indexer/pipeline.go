@@ -0,0 +1,194 @@
+package indexer
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	types2 "github.com/JackalLabs/jindexer/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/jackalLabs/canine-chain/v5/x/storage/types"
+	"github.com/rs/zerolog/log"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+// fetchedBlock is a block pulled from the RPC with its transactions already decoded,
+// waiting for the committer to write it.
+type fetchedBlock struct {
+	height int64
+	block  *tmtypes.Block
+	txs    []decodedTx
+	stats  []types2.MessageTypeStat
+	// storageParams are only fetched for blocks that need them, see needsStorageParams
+	storageParams *types.Params
+	skipped       bool // the height was already in the database
+	err           error
+}
+
+// decodedTx is a raw block transaction after running it through the TxDecoder,
+// paired with its DeliverTx result.
+type decodedTx struct {
+	hash   string
+	tx     sdk.Tx
+	result *abci.ResponseDeliverTx
+	err    error
+}
+
+// succeeded reports whether the transaction was executed successfully on chain.
+func (t decodedTx) succeeded() bool {
+	return t.result != nil && t.result.IsOK()
+}
+
+// msgResponses returns the response bytes of every message in the transaction, in message order.
+// Failed transactions and transactions without result data have no responses.
+func (t decodedTx) msgResponses() [][]byte {
+	if !t.succeeded() || len(t.result.Data) == 0 {
+		return nil
+	}
+
+	var txMsgData sdk.TxMsgData
+	err := txMsgData.Unmarshal(t.result.Data)
+	if err != nil {
+		log.Err(err).Str("tx", t.hash).Msg("failed to decode tx msg data")
+		return nil
+	}
+
+	responses := make([][]byte, len(txMsgData.Data))
+	for idx, msgData := range txMsgData.Data {
+		responses[idx] = msgData.Data
+	}
+	return responses
+}
+
+// decodeTxs runs every transaction of a block through the TxDecoder concurrently,
+// keeping the results in block order and pairing each one with its DeliverTx result.
+func (i *Indexer) decodeTxs(txs tmtypes.Txs, results []*abci.ResponseDeliverTx) []decodedTx {
+	decoded := make([]decodedTx, len(txs))
+	decoder := i.codec.TxConfig.TxDecoder()
+
+	var wg sync.WaitGroup
+	for idx, txBytes := range txs {
+		wg.Add(1)
+		go func(idx int, txBytes tmtypes.Tx) {
+			defer wg.Done()
+
+			tx, err := decoder(txBytes)
+			decoded[idx] = decodedTx{
+				hash:   hex.EncodeToString(txBytes.Hash()),
+				tx:     tx,
+				result: results[idx],
+				err:    err,
+			}
+		}(idx, txBytes)
+	}
+	wg.Wait()
+
+	return decoded
+}
+
+// runPipeline indexes heights from currentHeight onwards using three stages:
+//
+//  1. a dispatcher hands out heights, never more than PrefetchDepth ahead of the committer
+//  2. a pool of Workers fetch and decode those heights concurrently
+//  3. a single committer writes the results to the database in strict height order
+//
+// An unbounded run moves the configured cursor along with every committed height, bounded runs such
+// as backfills leave it alone so they never change where the live indexer resumes.
+func (i *Indexer) runPipeline(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cursor := ""
+	if i.endHeight <= 0 {
+		cursor = i.config.Cursor
+	}
+
+	heights := make(chan int64)
+	results := make(chan *fetchedBlock, i.config.PrefetchDepth)
+	slots := make(chan struct{}, i.config.PrefetchDepth)
+
+	// dispatcher
+	go func() {
+		defer close(heights)
+		for height := i.currentHeight; i.endHeight <= 0 || height < i.endHeight; height++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case heights <- height:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// fetchers
+	var wg sync.WaitGroup
+	for w := 0; w < i.config.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for height := range heights {
+				fetched := i.fetchWithRetry(ctx, height)
+				select {
+				case results <- fetched:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// committer, once ctx is cancelled nothing is committed anymore so a shutdown only waits for the
+	// block being committed
+	pending := make(map[int64]*fetchedBlock)
+	for fetched := range results {
+		pending[fetched.height] = fetched
+
+		for ctx.Err() == nil {
+			next, ok := pending[i.currentHeight]
+			if !ok {
+				break
+			}
+			delete(pending, i.currentHeight)
+
+			err := next.err
+			if err == nil {
+				err = i.withRetry(ctx, next.height, "commit", func() error {
+					return i.commitBlock(next, cursor)
+				})
+			}
+			if err != nil && ctx.Err() != nil {
+				// interrupted by the shutdown, the height is picked up again on the next run
+				return
+			}
+			if errors.Is(err, errBlockMismatch) {
+				i.halt(err)
+				return
+			}
+			if err != nil {
+				i.recordFailedHeight(next.height, err)
+				i.failedCount.Add(1)
+			} else {
+				i.committedCount.Add(1)
+			}
+
+			i.currentHeight++
+			<-slots
+		}
+	}
+
+	if len(pending) > 0 && ctx.Err() == nil {
+		log.Warn().Int("pending", len(pending)).Int64("current_height", i.currentHeight).Msg("pipeline stopped with uncommitted blocks")
+	}
+}
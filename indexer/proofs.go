@@ -0,0 +1,90 @@
+package indexer
+
+import (
+	"encoding/hex"
+
+	"github.com/JackalLabs/jindexer/database"
+	types2 "github.com/JackalLabs/jindexer/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/jackalLabs/canine-chain/v5/x/storage/types"
+	"github.com/rs/zerolog/log"
+)
+
+// processPostProof stores a proof from MsgPostProof or MsgPostProofFor. The chain reports a rejected
+// proof in the MsgPostProofResponse instead of failing the tx, so the response decides whether the
+// proof counts as proven.
+func (i *Indexer) processPostProof(tx *database.Database, msg sdk.Msg, block types2.Block, transaction types2.Transaction, msgIndex int, signer string, response []byte) error {
+	var msgPostProof *types.MsgPostProof
+	switch m := msg.(type) {
+	case *types.MsgPostProof:
+		msgPostProof = m
+	case *types.MsgPostProofFor:
+		// a claimer authorized by the provider submits the proof on its behalf, the provider is
+		// the prover and the claimer the signer
+		msgPostProof = &types.MsgPostProof{
+			Creator:  m.Provider,
+			Item:     m.Item,
+			HashList: m.HashList,
+			Merkle:   m.Merkle,
+			Owner:    m.Owner,
+			Start:    m.Start,
+			ToProve:  m.ToProve,
+		}
+		signer = m.Creator
+	default:
+		return nil
+	}
+
+	// Process the message
+	log.Info().Str("message_type", sdk.MsgTypeURL(msg)).Msg("processing proof")
+
+	merkle := hex.EncodeToString(msgPostProof.Merkle)
+	prover := msgPostProof.Creator
+
+	verified, reason := verifyProof(msgPostProof.Merkle, msgPostProof.HashList, msgPostProof.ToProve, msgPostProof.Item)
+	if !verified {
+		log.Warn().Str("merkle", merkle).Str("prover", prover).Str("reason", reason).Msg("proof failed offline verification")
+	}
+
+	// responses only exist for successful txs, failed ones are already flagged by their code
+	proofLog := transaction.Log
+	var rejected bool
+	if len(response) > 0 {
+		var res types.MsgPostProofResponse
+		if err := res.Unmarshal(response); err != nil {
+			return err
+		}
+		if !res.Success {
+			rejected = true
+			proofLog = res.ErrorMessage
+			log.Info().Str("merkle", merkle).Str("prover", prover).Str("error", res.ErrorMessage).Msg("proof rejected by the chain")
+		}
+	}
+
+	postProof := types2.PostProof{
+		Merkle:        merkle,
+		Prover:        prover,
+		Signer:        signer,
+		Owner:         msgPostProof.Owner,
+		Start:         msgPostProof.Start,
+		ToProve:       msgPostProof.ToProve,
+		Item:          hex.EncodeToString(msgPostProof.Item),
+		HashList:      string(msgPostProof.HashList),
+		Verified:      &verified,
+		VerifyReason:  reason,
+		Code:          transaction.Code,
+		Log:           proofLog,
+		Rejected:      rejected,
+		BlockId:       block.ID,
+		TransactionId: transaction.ID,
+		MessageIndex:  msgIndex,
+	}
+
+	err := tx.SavePostProof(&postProof)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package indexer
+
+import (
+	"github.com/JackalLabs/jindexer/database"
+	types2 "github.com/JackalLabs/jindexer/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/jackalLabs/canine-chain/v5/x/storage/types"
+	"github.com/rs/zerolog/log"
+)
+
+// processProviderMessage applies a provider lifecycle message to the providers table.
+func (i *Indexer) processProviderMessage(tx *database.Database, msg sdk.Msg, block types2.Block, transaction types2.Transaction) error {
+	// failed messages never changed the provider on chain
+	if transaction.Code != 0 {
+		return nil
+	}
+
+	event := types2.ProviderEvent{
+		BlockId:       block.ID,
+		TransactionId: transaction.ID,
+	}
+
+	switch m := msg.(type) {
+	case *types.MsgInitProvider:
+		event.Address = m.Creator
+		event.Action = "init"
+		event.IP = m.Ip
+		event.Keybase = m.Keybase
+		event.TotalSpace = m.TotalSpace
+	case *types.MsgSetProviderIP:
+		event.Address = m.Creator
+		event.Action = "set_ip"
+		event.IP = m.Ip
+	case *types.MsgSetProviderTotalSpace:
+		event.Address = m.Creator
+		event.Action = "set_total_space"
+		event.TotalSpace = m.Space
+	case *types.MsgSetProviderKeybase:
+		event.Address = m.Creator
+		event.Action = "set_keybase"
+		event.Keybase = m.Keybase
+	case *types.MsgShutdownProvider:
+		event.Address = m.Creator
+		event.Action = "shutdown"
+	default:
+		return nil
+	}
+
+	log.Info().Str("provider", event.Address).Str("action", event.Action).Msg("processing provider message")
+
+	return tx.UpdateProvider(&event)
+}
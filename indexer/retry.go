@@ -0,0 +1,128 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// failedHeightsBatch is how many recorded failed heights are retried per pass.
+const failedHeightsBatch = 100
+
+// backoffDelay returns the delay before the given retry attempt (starting at 1),
+// doubling RetryBaseDelay each time and capping it at RetryMaxDelay.
+func (i *Indexer) backoffDelay(attempt int) time.Duration {
+	delay := i.config.RetryBaseDelay
+	for n := 1; n < attempt; n++ {
+		delay *= 2
+		if delay >= i.config.RetryMaxDelay {
+			return i.config.RetryMaxDelay
+		}
+	}
+	return delay
+}
+
+// withRetry calls fn until it succeeds or MaxRetries retries have been used up,
+// sleeping with exponential backoff between attempts. The last error is returned.
+// A block mismatch is returned right away, retrying cannot fix it.
+func (i *Indexer) withRetry(ctx context.Context, height int64, stage string, fn func() error) error {
+	err := fn()
+	for attempt := 1; err != nil && !errors.Is(err, errBlockMismatch) && attempt <= i.config.MaxRetries; attempt++ {
+		delay := i.backoffDelay(attempt)
+		log.Warn().Err(err).
+			Int64("height", height).
+			Str("stage", stage).
+			Int("attempt", attempt).
+			Dur("delay", delay).
+			Msg("retrying height")
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		err = fn()
+	}
+	return err
+}
+
+// fetchWithRetry fetches a height, retrying RPC failures with backoff.
+func (i *Indexer) fetchWithRetry(ctx context.Context, height int64) *fetchedBlock {
+	var fetched *fetchedBlock
+	err := i.withRetry(ctx, height, "fetch", func() error {
+		fetched = i.fetchBlock(ctx, height)
+		return fetched.err
+	})
+	if err != nil {
+		fetched.err = err
+	}
+	return fetched
+}
+
+// indexBlockWithRetry indexes a height outside of the pipeline, retrying with backoff.
+func (i *Indexer) indexBlockWithRetry(ctx context.Context, height int64) error {
+	return i.withRetry(ctx, height, "index", func() error {
+		return i.indexBlock(ctx, height)
+	})
+}
+
+// recordFailedHeight stores a height that could not be indexed so it is picked up again later.
+func (i *Indexer) recordFailedHeight(height int64, cause error) {
+	log.Error().Err(cause).Int64("height", height).Msg("giving up on height for now, recording it as failed")
+
+	err := i.database.RecordFailedHeight(height, cause.Error())
+	if err != nil {
+		log.Err(err).Int64("height", height).Msg("failed to record failed height")
+	}
+}
+
+// retryFailedHeights periodically works through the failed heights table,
+// removing every height that indexes successfully this time around.
+func (i *Indexer) retryFailedHeights(ctx context.Context) {
+	ticker := time.NewTicker(i.config.FailedHeightsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		failed, err := i.database.ListFailedHeights(failedHeightsBatch)
+		if err != nil {
+			log.Err(err).Msg("failed to list failed heights")
+			continue
+		}
+		if len(failed) == 0 {
+			continue
+		}
+
+		log.Info().Int("count", len(failed)).Msg("Retrying failed heights")
+
+		for _, f := range failed {
+			err := i.indexBlockWithRetry(ctx, f.Height)
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, errBlockMismatch) {
+				i.halt(err)
+				return
+			}
+			if err != nil {
+				i.recordFailedHeight(f.Height, err)
+				continue
+			}
+
+			err = i.database.DeleteFailedHeight(f.Height)
+			if err != nil {
+				log.Err(err).Int64("height", f.Height).Msg("failed to clear failed height")
+				continue
+			}
+			log.Info().Int64("height", f.Height).Msg("Recovered failed height")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package indexer
+
+import (
+	"sort"
+
+	types2 "github.com/JackalLabs/jindexer/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// messageTypeStats counts the messages of a block per type URL, handled or not, including messages of
+// failed transactions. Messages carried by a wrapper are counted as well as the wrapper itself.
+func (i *Indexer) messageTypeStats(txs []decodedTx) []types2.MessageTypeStat {
+	counts := make(map[string]*types2.MessageTypeStat)
+
+	var count func(msg sdk.Msg, failed bool)
+	count = func(msg sdk.Msg, failed bool) {
+		typeURL := sdk.MsgTypeURL(msg)
+		stat, ok := counts[typeURL]
+		if !ok {
+			stat = &types2.MessageTypeStat{TypeURL: typeURL}
+			counts[typeURL] = stat
+		}
+		stat.Count++
+		if failed {
+			stat.Failed++
+		}
+
+		if inner, _, ok := i.wrappedMessages(msg, nil); ok {
+			for _, innerMsg := range inner {
+				if innerMsg != nil {
+					count(innerMsg, failed)
+				}
+			}
+		}
+	}
+
+	for _, t := range txs {
+		if t.tx == nil {
+			continue
+		}
+		for _, msg := range t.tx.GetMsgs() {
+			count(msg, !t.succeeded())
+		}
+	}
+
+	stats := make([]types2.MessageTypeStat, 0, len(counts))
+	for _, stat := range counts {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(a, b int) bool {
+		return stats[a].TypeURL < stats[b].TypeURL
+	})
+
+	return stats
+}
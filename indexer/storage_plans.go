@@ -0,0 +1,50 @@
+package indexer
+
+import (
+	"time"
+
+	"github.com/JackalLabs/jindexer/database"
+	types2 "github.com/JackalLabs/jindexer/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/jackalLabs/canine-chain/v5/x/storage/types"
+	"github.com/rs/zerolog/log"
+)
+
+func (i *Indexer) processBuyStorage(tx *database.Database, msg sdk.Msg, block types2.Block, transaction types2.Transaction) error {
+	msgBuyStorage, ok := msg.(*types.MsgBuyStorage)
+	if !ok {
+		return nil
+	}
+
+	// nothing was bought if the tx failed
+	if transaction.Code != 0 {
+		return nil
+	}
+
+	log.Info().Msg("processing MsgBuyStorage")
+
+	// canine-chain buys for the creator when no address is given. RNS names are stored as given
+	// since resolving them would need the chain state at this height.
+	owner := msgBuyStorage.ForAddress
+	if owner == "" {
+		owner = msgBuyStorage.Creator
+	}
+
+	duration := time.Duration(msgBuyStorage.DurationDays) * time.Hour * 24
+
+	purchase := types2.StoragePurchase{
+		Owner:         owner,
+		Purchaser:     msgBuyStorage.Creator,
+		Bytes:         msgBuyStorage.Bytes,
+		DurationDays:  msgBuyStorage.DurationDays,
+		PaymentDenom:  msgBuyStorage.PaymentDenom,
+		Referral:      msgBuyStorage.Referral,
+		Start:         block.Time,
+		End:           block.Time.Add(duration),
+		BlockId:       block.ID,
+		TransactionId: transaction.ID,
+	}
+
+	return tx.SaveStoragePurchase(&purchase, block.Height)
+}
@@ -0,0 +1,169 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	types2 "github.com/JackalLabs/jindexer/types"
+
+	"github.com/rs/zerolog/log"
+)
+
+// syncCheckpointInterval is how many heights of a chunk are indexed between checkpoints.
+const syncCheckpointInterval = 100
+
+// Sync indexes every height from from to to (inclusive) by splitting the range into chunks of
+// chunkSize heights that workers index at the same time, each height through indexBlock. The progress
+// of every chunk is checkpointed, so running the same sync again after a crash only resumes the
+// unfinished chunks. Once all chunks are done the range is scanned for gaps, which are repaired.
+// It returns an error if the range is not fully covered in the end.
+func (i *Indexer) Sync(ctx context.Context, from, to, chunkSize int64, workers int) error {
+	if from < 1 || to < from {
+		return fmt.Errorf("invalid sync range %d-%d", from, to)
+	}
+	if chunkSize < 1 {
+		return fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	chunks, err := i.database.PlanSyncChunks(from, to, chunkSize)
+	if err != nil {
+		return fmt.Errorf("failed to plan sync chunks: %w", err)
+	}
+
+	var remaining int64
+	for _, chunk := range chunks {
+		remaining += chunk.End - chunk.Next + 1
+	}
+
+	log.Info().
+		Str("chain_id", i.chainID).
+		Int64("from", from).
+		Int64("to", to).
+		Int("chunks", len(chunks)).
+		Int64("heights", remaining).
+		Int("workers", workers).
+		Msg("Starting sync")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go i.watchEndpoints(ctx)
+	go i.reportProgress(ctx, remaining)
+
+	// a block mismatch stops every worker, the chain or the index needs looking into first
+	var mismatchOnce sync.Once
+	var mismatch error
+
+	queue := make(chan types2.SyncChunk)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range queue {
+				err := i.syncChunk(ctx, chunk)
+				if errors.Is(err, errBlockMismatch) {
+					mismatchOnce.Do(func() {
+						mismatch = err
+						cancel()
+					})
+				}
+				if err != nil {
+					log.Err(err).Int64("start", chunk.Start).Int64("end", chunk.End).Msg("sync chunk interrupted")
+				}
+			}
+		}()
+	}
+
+	for _, chunk := range chunks {
+		select {
+		case queue <- chunk:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if mismatch != nil {
+		return mismatch
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// confirm the whole range is covered, whatever the chunks recorded
+	gaps, err := i.ScanGaps(from, to)
+	if err != nil {
+		return fmt.Errorf("failed to scan for gaps: %w", err)
+	}
+	if len(gaps) > 0 {
+		log.Warn().Int("gaps", len(gaps)).Msg("sync left gaps, repairing them")
+		i.RepairGaps(ctx, gaps)
+		if err := i.halted(); err != nil {
+			return err
+		}
+
+		gaps, err = i.ScanGaps(from, to)
+		if err != nil {
+			return fmt.Errorf("failed to scan for gaps: %w", err)
+		}
+	}
+
+	var missing int64
+	for _, gap := range gaps {
+		missing += gap.Size()
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d heights in %d gaps could not be indexed and were recorded as failed", missing, len(gaps))
+	}
+
+	log.Info().
+		Str("chain_id", i.chainID).
+		Int64("from", from).
+		Int64("to", to).
+		Int64("indexed", i.committedCount.Load()).
+		Int64("failed", i.failedCount.Load()).
+		Msg("Sync complete, range fully covered")
+
+	return nil
+}
+
+// syncChunk indexes the unfinished part of a chunk, checkpointing its progress as it goes.
+func (i *Indexer) syncChunk(ctx context.Context, chunk types2.SyncChunk) error {
+	failed := chunk.Failed
+
+	for height := chunk.Next; height <= chunk.End; height++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err := i.indexBlockWithRetry(ctx, height)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, errBlockMismatch) {
+				return err
+			}
+			i.recordFailedHeight(height, err)
+			i.failedCount.Add(1)
+			failed++
+		} else {
+			i.committedCount.Add(1)
+		}
+
+		if height == chunk.End || (height-chunk.Start+1)%syncCheckpointInterval == 0 {
+			err = i.database.CheckpointSyncChunk(chunk.ID, height+1, failed, height == chunk.End)
+			if err != nil {
+				return fmt.Errorf("failed to checkpoint chunk: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
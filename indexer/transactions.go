@@ -0,0 +1,54 @@
+package indexer
+
+import (
+	"strings"
+
+	types2 "github.com/JackalLabs/jindexer/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
+	canine "github.com/jackalLabs/canine-chain/v5/app"
+)
+
+// buildTransaction turns a decoded tx into its database record. Transactions that could not be
+// decoded are still recorded with their hash and result so proofs and failures stay traceable.
+func (i *Indexer) buildTransaction(t decodedTx, idx int, block types2.Block) types2.Transaction {
+	transaction := types2.Transaction{
+		Hash:    t.hash,
+		Index:   idx,
+		BlockId: block.ID,
+	}
+
+	if t.result != nil {
+		transaction.GasWanted = t.result.GasWanted
+		transaction.GasUsed = t.result.GasUsed
+		transaction.Code = t.result.Code
+		transaction.Log = t.result.Log
+	}
+
+	if t.tx == nil {
+		return transaction
+	}
+
+	if sigTx, ok := t.tx.(authsigning.SigVerifiableTx); ok {
+		signers := make([]string, 0, len(sigTx.GetSigners()))
+		for _, signer := range sigTx.GetSigners() {
+			address, err := sdk.Bech32ifyAddressBytes(canine.Bech32PrefixAccAddr, signer)
+			if err != nil {
+				continue
+			}
+			signers = append(signers, address)
+		}
+		transaction.Signers = strings.Join(signers, ",")
+	}
+
+	if feeTx, ok := t.tx.(sdk.FeeTx); ok {
+		transaction.Fee = feeTx.GetFee().String()
+	}
+
+	if memoTx, ok := t.tx.(sdk.TxWithMemo); ok {
+		transaction.Memo = memoTx.GetMemo()
+	}
+
+	return transaction
+}
@@ -0,0 +1,51 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/sha3"
+)
+
+// verifyProof checks a proven chunk against the file's merkle root the same way canine-chain's
+// UnifiedFile.VerifyProof does: the leaf is the sha256 of the chunk index followed by the hex
+// encoded chunk, and the hash list is a JSON merkle proof hashed with sha3-512.
+// It returns whether the proof is valid and, if it is not, the reason.
+func verifyProof(merkle []byte, hashList []byte, toProve int64, item []byte) (valid bool, reason string) {
+	if len(merkle) == 0 {
+		return false, "empty merkle"
+	}
+	if len(hashList) == 0 {
+		return false, "empty hash list"
+	}
+
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%d%x", toProve, item)
+	leaf := h.Sum(nil)
+
+	var proof merkletree.Proof
+	err := json.Unmarshal(hashList, &proof)
+	if err != nil {
+		return false, fmt.Sprintf("malformed hash list: %s", err)
+	}
+
+	// a crafted proof must not be able to take the indexer down
+	defer func() {
+		if r := recover(); r != nil {
+			valid = false
+			reason = fmt.Sprintf("malformed hash list: %v", r)
+		}
+	}()
+
+	verified, err := merkletree.VerifyProofUsing(leaf, false, &proof, [][]byte{merkle}, sha3.New512())
+	if err != nil {
+		return false, err.Error()
+	}
+	if !verified {
+		return false, fmt.Sprintf("chunk %d does not hash to the merkle root", toProve)
+	}
+
+	return true, ""
+}
@@ -0,0 +1,116 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/sha3"
+)
+
+// chunkLeaf hashes a chunk the way providers build the leaves of a file's merkle tree.
+func chunkLeaf(index int, chunk []byte) []byte {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%d%x", index, chunk)
+	return h.Sum(nil)
+}
+
+func TestVerifyProof(t *testing.T) {
+	chunks := [][]byte{
+		[]byte("Hello world!"),
+		[]byte("second chunk"),
+		[]byte("third chunk"),
+		[]byte("fourth chunk"),
+	}
+
+	leaves := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		leaves[i] = chunkLeaf(i, chunk)
+	}
+
+	tree, err := merkletree.NewUsing(leaves, sha3.New512(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proof, err := tree.GenerateProof(leaves[1], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashList, err := json.Marshal(*proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		merkle   []byte
+		hashList []byte
+		toProve  int64
+		item     []byte
+		valid    bool
+		reason   string
+	}{
+		{
+			name:     "valid proof",
+			merkle:   tree.Root(),
+			hashList: hashList,
+			toProve:  1,
+			item:     chunks[1],
+			valid:    true,
+		},
+		{
+			name:     "wrong chunk index",
+			merkle:   tree.Root(),
+			hashList: hashList,
+			toProve:  2,
+			item:     chunks[1],
+			reason:   "chunk 2 does not hash to the merkle root",
+		},
+		{
+			name:     "wrong chunk",
+			merkle:   tree.Root(),
+			hashList: hashList,
+			toProve:  1,
+			item:     chunks[2],
+			reason:   "chunk 1 does not hash to the merkle root",
+		},
+		{
+			name:     "malformed hash list",
+			merkle:   tree.Root(),
+			hashList: []byte("not a proof"),
+			toProve:  1,
+			item:     chunks[1],
+			reason:   "malformed hash list",
+		},
+		{
+			name:    "empty hash list",
+			merkle:  tree.Root(),
+			toProve: 1,
+			item:    chunks[1],
+			reason:  "empty hash list",
+		},
+		{
+			name:     "empty merkle",
+			hashList: hashList,
+			toProve:  1,
+			item:     chunks[1],
+			reason:   "empty merkle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, reason := verifyProof(tt.merkle, tt.hashList, tt.toProve, tt.item)
+			if valid != tt.valid {
+				t.Fatalf("valid = %v, want %v (reason %q)", valid, tt.valid, reason)
+			}
+			if !strings.HasPrefix(reason, tt.reason) {
+				t.Fatalf("reason = %q, want prefix %q", reason, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package indexer
+
+import (
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+	"github.com/rs/zerolog/log"
+)
+
+// wrappedMessages returns the messages carried by a wrapper message such as authz MsgExec, along
+// with their responses taken from the wrapper's response. ok is false if msg is not a wrapper.
+// Inner messages that cannot be unpacked through the interface registry are skipped.
+func (i *Indexer) wrappedMessages(msg sdk.Msg, response []byte) (msgs []sdk.Msg, responses [][]byte, ok bool) {
+	var anys []*codectypes.Any
+
+	switch m := msg.(type) {
+	case *authz.MsgExec:
+		anys = m.Msgs
+		if len(response) > 0 {
+			var res authz.MsgExecResponse
+			err := res.Unmarshal(response)
+			if err != nil {
+				log.Err(err).Msg("failed to decode MsgExec response")
+			} else {
+				responses = res.Results
+			}
+		}
+	default:
+		return nil, nil, false
+	}
+
+	msgs = make([]sdk.Msg, len(anys))
+	for idx, msgAny := range anys {
+		var inner sdk.Msg
+		err := i.codec.InterfaceRegistry.UnpackAny(msgAny, &inner)
+		if err != nil {
+			log.Err(err).Str("message_type_url", msgAny.TypeUrl).Msg("failed to unpack wrapped message")
+			continue
+		}
+		msgs[idx] = inner
+	}
+
+	return msgs, responses, true
+}
@@ -2,83 +2,238 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/JackalLabs/jindexer/database"
 	"github.com/JackalLabs/jindexer/indexer"
 	"github.com/JackalLabs/jindexer/utils"
-	"github.com/cosmos/cosmos-sdk/client"
 	canine "github.com/jackalLabs/canine-chain/v5/app"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 func main() {
 	utils.InitLogger("Starting JIndexer")
 
-	// Get RPC and gRPC endpoints from environment variables
-	rpcEndpoint := os.Getenv("JACKAL_RPC_URL")
-	if rpcEndpoint == "" {
-		rpcEndpoint = "https://jackal-rpc.polkachu.com:443"
+	ctx := shutdownContext()
+
+	// Subcommands (e.g. `jindexer gaps`) run once and exit instead of starting the indexer
+	if len(os.Args) > 1 {
+		runCommand(ctx, os.Args[1], os.Args[2:])
+		return
 	}
 
-	grpcEndpoint := os.Getenv("JACKAL_GRPC_URL")
-	if grpcEndpoint == "" {
-		grpcEndpoint = "jackal-grpc.polkachu.com:17590"
+	d, err := database.NewDatabase()
+	if err != nil {
+		panic(err)
 	}
 
-	// Get start height from environment variable
-	startHeightStr := os.Getenv("JINDEXER_START_HEIGHT")
-	var startHeight int64
-	if startHeightStr == "" {
-		startHeight = 0 // Default to 0, which means current block height
-	} else {
-		var err error
-		startHeight, err = strconv.ParseInt(startHeightStr, 10, 64)
-		if err != nil {
-			log.Fatal().Err(err).Msg("failed to parse JINDEXER_START_HEIGHT")
-		}
+	// Every configured network gets its own indexer, all sharing the same database. An indexer that
+	// halts shuts the others down as well so the process exits with an error and gets noticed.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var halted atomic.Bool
+	var wg sync.WaitGroup
+	for _, n := range networks() {
+		wg.Add(1)
+		go func(n network) {
+			defer wg.Done()
+			err := runNetwork(ctx, d, n)
+			if err != nil {
+				log.Error().Err(err).Str("network", n.Name).Msg("indexer stopped with an error, shutting down")
+				halted.Store(true)
+				cancel()
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	err = d.Close()
+	if err != nil {
+		log.Err(err).Msg("failed to close database")
 	}
+	if halted.Load() {
+		os.Exit(1)
+	}
+	log.Info().Msg("JIndexer stopped")
+}
 
-	encodingCfg := canine.MakeEncodingConfig()
+// shutdownContext returns a context cancelled on SIGINT or SIGTERM. A second signal kills the process
+// without waiting for the shutdown to finish.
+func shutdownContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		log.Info().Msg("Shutting down, finishing the blocks being committed (signal again to force)")
+	}()
+	return ctx
+}
 
-	d, err := database.NewDatabase()
+// runNetwork indexes a single network until its indexer stops or ctx is cancelled. It returns an error
+// if the network could not be set up or the indexer halted, e.g. because the RPC served a block that
+// does not match the indexed chain.
+func runNetwork(ctx context.Context, d *database.Database, n network) error {
+	rpcClient, err := dialNetwork(ctx, n)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to connect to network: %w", err)
+	}
+
+	d, err = networkDatabase(ctx, d, rpcClient, n)
+	if err != nil {
+		return fmt.Errorf("failed to resolve chain ID: %w", err)
+	}
+
+	config := n.indexerConfig()
+	startHeight := n.StartHeight
+
+	// If startHeight is 0, resume after the height the indexer's cursor last committed
+	if startHeight == 0 {
+		cursor, err := d.GetCursor(config.Cursor)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to read indexer cursor: %w", err)
+		}
+		if cursor != nil {
+			startHeight = cursor.Height + 1
+			log.Info().Str("chain_id", d.ChainID()).Str("cursor", cursor.Name).Int64("start_height", startHeight).Int64("last_indexed_height", cursor.Height).Msg("Resuming from cursor")
+		}
 	}
 
-	// If startHeight is 0, try to get the most recent block from the database first,
-	// then fall back to current block height from RPC if there's an error
+	// Without a cursor (a new database, one indexed before cursors existed or a reset cursor) try the most
+	// recent block from the database first, then fall back to current block height from RPC if there's an error
 	if startHeight == 0 {
 		mostRecentHeight, err := d.GetMostRecentBlockHeight()
 		if err == nil {
 			// Start from the next block after the most recent one
 			startHeight = mostRecentHeight + 1
-			log.Info().Int64("start_height", startHeight).Int64("last_indexed_height", mostRecentHeight).Msg("Starting after most recently saved block")
+			log.Info().Str("chain_id", d.ChainID()).Int64("start_height", startHeight).Int64("last_indexed_height", mostRecentHeight).Msg("Starting after most recently saved block")
 		} else {
 			// If there's an error (e.g., no blocks in database), fall back to current block height from RPC
-			log.Warn().Err(err).Msg("Failed to get most recent block from database, falling back to current block height from RPC")
-
-			rpcClient, err := client.NewClientFromNode(rpcEndpoint)
-			if err != nil {
-				log.Fatal().Err(err).Msg("failed to create RPC client to get current block height")
-			}
+			log.Warn().Err(err).Str("chain_id", d.ChainID()).Msg("Failed to get most recent block from database, falling back to current block height from RPC")
 
-			ctx := context.Background()
 			abciInfo, err := rpcClient.ABCIInfo(ctx)
 			if err != nil {
-				log.Fatal().Err(err).Msg("failed to get current block height from RPC")
+				return fmt.Errorf("failed to get current block height from RPC: %w", err)
 			}
 
 			startHeight = abciInfo.Response.LastBlockHeight
-			log.Info().Int64("start_height", startHeight).Msg("Starting from current block height")
+			log.Info().Str("chain_id", d.ChainID()).Int64("start_height", startHeight).Msg("Starting from current block height")
 		}
 	}
 
-	i, err := indexer.NewIndexer(rpcEndpoint, grpcEndpoint, encodingCfg, d, startHeight, 0)
+	i, err := indexer.NewIndexer(n.rpcEndpoints(), n.grpcEndpoints(), canine.MakeEncodingConfig(), d, startHeight, 0, config)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to create indexer: %w", err)
+	}
+	defer func() {
+		err := i.Close()
+		if err != nil {
+			log.Err(err).Str("chain_id", i.ChainID()).Msg("failed to close indexer connections")
+		}
+	}()
+
+	err = i.Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// endpoints returns the RPC and gRPC endpoints from environment variables. JACKAL_RPC_URLS and
+// JACKAL_GRPC_URLS take comma separated lists, falling back to the single JACKAL_RPC_URL and JACKAL_GRPC_URL.
+func endpoints() ([]string, []string) {
+	rpcEndpoints := envList("JACKAL_RPC_URLS")
+	if len(rpcEndpoints) == 0 {
+		rpcEndpoint := os.Getenv("JACKAL_RPC_URL")
+		if rpcEndpoint == "" {
+			rpcEndpoint = "https://jackal-rpc.polkachu.com:443"
+		}
+		rpcEndpoints = []string{rpcEndpoint}
+	}
+
+	grpcEndpoints := envList("JACKAL_GRPC_URLS")
+	if len(grpcEndpoints) == 0 {
+		grpcEndpoint := os.Getenv("JACKAL_GRPC_URL")
+		if grpcEndpoint == "" {
+			grpcEndpoint = "jackal-grpc.polkachu.com:17590"
+		}
+		grpcEndpoints = []string{grpcEndpoint}
+	}
+
+	return rpcEndpoints, grpcEndpoints
+}
+
+// indexerConfig builds the indexer configuration, applying overrides from environment variables
+func indexerConfig() indexer.Config {
+	config := indexer.DefaultConfig()
+	config.Workers = envInt("JINDEXER_WORKERS", config.Workers)
+	config.PrefetchDepth = envInt("JINDEXER_PREFETCH_DEPTH", config.PrefetchDepth)
+	config.MaxRetries = envInt("JINDEXER_MAX_RETRIES", config.MaxRetries)
+	config.FailedHeightsInterval = envDuration("JINDEXER_FAILED_RETRY_INTERVAL", config.FailedHeightsInterval)
+	config.GapScanInterval = envDuration("JINDEXER_GAP_SCAN_INTERVAL", config.GapScanInterval)
+	if followMode := os.Getenv("JINDEXER_FOLLOW_MODE"); followMode != "" {
+		config.FollowMode = followMode
+	}
+	config.PollInterval = envDuration("JINDEXER_POLL_INTERVAL", config.PollInterval)
+	config.EndpointCheckInterval = envDuration("JINDEXER_ENDPOINT_CHECK_INTERVAL", config.EndpointCheckInterval)
+	config.EnabledHandlers = envList("JINDEXER_HANDLERS")
+	config.DisabledHandlers = envList("JINDEXER_DISABLED_HANDLERS")
+	if cursor := os.Getenv("JINDEXER_CURSOR"); cursor != "" {
+		config.Cursor = cursor
+	}
+	config.TrustingPeriod = envDuration("JINDEXER_TRUSTING_PERIOD", config.TrustingPeriod)
+	return config
+}
+
+// envInt reads an integer from the environment, returning def when the variable is unset.
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatal().Err(err).Str("key", key).Msg("failed to parse environment variable")
+	}
+
+	return parsed
+}
+
+// envDuration reads a duration (e.g. "5m") from the environment, returning def when the variable is unset.
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatal().Err(err).Str("key", key).Msg("failed to parse environment variable")
+	}
+
+	return parsed
+}
+
+// envList reads a comma separated list (e.g. "proofs,files") from the environment, returning nil when the variable is unset.
+func envList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
 	}
 
-	i.Start()
+	return list
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/JackalLabs/jindexer/database"
+	"github.com/JackalLabs/jindexer/indexer"
+	sdkclient "github.com/cosmos/cosmos-sdk/client"
+	"github.com/rs/zerolog/log"
+	"github.com/tendermint/tendermint/rpc/client"
+	"github.com/tendermint/tendermint/rpc/client/http"
+)
+
+// network is a chain to index. Several networks can be indexed into the same database by listing
+// them as a JSON array in JINDEXER_NETWORKS, e.g.
+//
+//	[{"name":"mainnet","rpc":"https://...","grpc":"host:port"},{"name":"testnet",...}]
+//
+// Several endpoints of a network can be listed in rpcs and grpcs, the indexer fails over between them.
+// Without JINDEXER_NETWORKS a single network is configured from JACKAL_RPC_URLS (or JACKAL_RPC_URL),
+// JACKAL_GRPC_URLS (or JACKAL_GRPC_URL), JINDEXER_START_HEIGHT, JINDEXER_TRUSTED_HEIGHT and JINDEXER_TRUSTED_HASH.
+type network struct {
+	Name  string   `json:"name"`
+	RPC   string   `json:"rpc"`
+	GRPC  string   `json:"grpc"`
+	RPCs  []string `json:"rpcs"`
+	GRPCs []string `json:"grpcs"`
+	// ChainID is optional, when set the chain ID reported by the RPC must match it
+	ChainID string `json:"chain_id"`
+	// StartHeight is the first height to index, 0 resumes after the most recently indexed block
+	StartHeight int64 `json:"start_height"`
+	// AdoptLegacy tags rows indexed before networks were tracked with this network's chain ID
+	AdoptLegacy bool `json:"adopt_legacy"`
+	// TrustedHeight and TrustedHash (hex) enable light client verification of fetched blocks
+	TrustedHeight int64  `json:"trusted_height"`
+	TrustedHash   string `json:"trusted_hash"`
+}
+
+// networks returns the configured networks.
+func networks() []network {
+	if config := os.Getenv("JINDEXER_NETWORKS"); config != "" {
+		var list []network
+		err := json.Unmarshal([]byte(config), &list)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to parse JINDEXER_NETWORKS")
+		}
+		if len(list) == 0 {
+			log.Fatal().Msg("JINDEXER_NETWORKS has no networks")
+		}
+		for _, n := range list {
+			if n.Name == "" || len(n.rpcEndpoints()) == 0 || len(n.grpcEndpoints()) == 0 {
+				log.Fatal().Str("network", n.Name).Msg("every network in JINDEXER_NETWORKS needs a name, rpc and grpc")
+			}
+		}
+		return list
+	}
+
+	rpcEndpoints, grpcEndpoints := endpoints()
+
+	// Get start height from environment variable
+	var startHeight int64
+	if startHeightStr := os.Getenv("JINDEXER_START_HEIGHT"); startHeightStr != "" {
+		var err error
+		startHeight, err = strconv.ParseInt(startHeightStr, 10, 64)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to parse JINDEXER_START_HEIGHT")
+		}
+	}
+
+	var trustedHeight int64
+	if trustedHeightStr := os.Getenv("JINDEXER_TRUSTED_HEIGHT"); trustedHeightStr != "" {
+		var err error
+		trustedHeight, err = strconv.ParseInt(trustedHeightStr, 10, 64)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to parse JINDEXER_TRUSTED_HEIGHT")
+		}
+	}
+
+	// a single network owns everything indexed before networks were tracked
+	return []network{{
+		Name:          "default",
+		RPCs:          rpcEndpoints,
+		GRPCs:         grpcEndpoints,
+		StartHeight:   startHeight,
+		AdoptLegacy:   true,
+		TrustedHeight: trustedHeight,
+		TrustedHash:   os.Getenv("JINDEXER_TRUSTED_HASH"),
+	}}
+}
+
+// indexerConfig returns the indexer configuration for the network, the shared configuration with the
+// network's trusted header.
+func (n network) indexerConfig() indexer.Config {
+	config := indexerConfig()
+	config.TrustedHeight = n.TrustedHeight
+	config.TrustedHash = n.TrustedHash
+	return config
+}
+
+// findNetwork returns the configured network with the given name, or the first one if name is empty.
+func findNetwork(name string) (network, error) {
+	list := networks()
+	if name == "" {
+		return list[0], nil
+	}
+
+	for _, n := range list {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return network{}, fmt.Errorf("unknown network %q", name)
+}
+
+// rpcEndpoints returns every RPC endpoint of the network.
+func (n network) rpcEndpoints() []string {
+	if n.RPC == "" {
+		return n.RPCs
+	}
+	return append([]string{n.RPC}, n.RPCs...)
+}
+
+// grpcEndpoints returns every gRPC endpoint of the network.
+func (n network) grpcEndpoints() []string {
+	if n.GRPC == "" {
+		return n.GRPCs
+	}
+	return append([]string{n.GRPC}, n.GRPCs...)
+}
+
+// dialNetwork returns a client for the first RPC endpoint of the network that answers.
+func dialNetwork(ctx context.Context, n network) (*http.HTTP, error) {
+	var err error
+	for _, endpoint := range n.rpcEndpoints() {
+		var rpcClient *http.HTTP
+		rpcClient, err = sdkclient.NewClientFromNode(endpoint)
+		if err != nil {
+			continue
+		}
+
+		_, err = rpcClient.Status(ctx)
+		if err == nil {
+			return rpcClient, nil
+		}
+		log.Warn().Err(err).Str("network", n.Name).Str("endpoint", endpoint).Msg("RPC endpoint unreachable")
+	}
+	return nil, fmt.Errorf("no RPC endpoint of network %s is reachable: %w", n.Name, err)
+}
+
+// networkDatabase scopes the database to the chain served by the network's RPC, adopting legacy
+// rows if the network is configured to.
+func networkDatabase(ctx context.Context, d *database.Database, rpcClient client.StatusClient, n network) (*database.Database, error) {
+	status, err := rpcClient.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chainID := status.NodeInfo.Network
+	if n.ChainID != "" && n.ChainID != chainID {
+		return nil, fmt.Errorf("network %s is configured for chain %s but its RPC serves %s", n.Name, n.ChainID, chainID)
+	}
+	d = d.ForChain(chainID)
+
+	if n.AdoptLegacy {
+		adopted, err := d.AdoptLegacyRows()
+		if err != nil {
+			return nil, err
+		}
+		if adopted > 0 {
+			log.Info().Str("chain_id", chainID).Int64("rows", adopted).Msg("adopted rows indexed before networks were tracked")
+		}
+	}
+
+	return d, nil
+}
@@ -6,19 +6,301 @@ import (
 	"gorm.io/gorm"
 )
 
+// Block is an indexed block. Like every model here it carries the chain ID of the network it was
+// indexed from, so a single database can hold several networks. Rows indexed before networks were
+// tracked have an empty chain ID until a network adopts them.
 type Block struct {
 	gorm.Model
 
-	Height int64     `json:"height" gorm:"uniqueIndex"`
-	Time   time.Time `json:"time" gorm:"index:idx_blocks_time,sort:desc"`
+	ChainID string    `json:"chainId" gorm:"uniqueIndex:idx_blocks_chain_height"`
+	Height  int64     `json:"height" gorm:"uniqueIndex:idx_blocks_chain_height"`
+	Time    time.Time `json:"time" gorm:"index:idx_blocks_time,sort:desc"`
+
+	// Header fields, hex encoded. They are empty for blocks indexed before they were recorded.
+	Hash            string `json:"hash" gorm:"index"`
+	LastBlockHash   string `json:"lastBlockHash"` // hash of the block at Height-1, from the header's LastBlockID
+	AppHash         string `json:"appHash"`
+	ProposerAddress string `json:"proposerAddress" gorm:"index"`
+	TxCount         int    `json:"txCount"`
+}
+
+// Transaction is a transaction included in a block, along with its DeliverTx result.
+type Transaction struct {
+	gorm.Model
+
+	ChainID string `json:"chainId" gorm:"uniqueIndex:idx_transactions_chain_hash"`
+	Hash    string `json:"hash" gorm:"uniqueIndex:idx_transactions_chain_hash"`
+	Index   int    `json:"index" gorm:"column:tx_index"` // position of the tx within its block
+
+	Signers   string `json:"signers" gorm:"index"` // comma separated bech32 addresses
+	Fee       string `json:"fee"`
+	GasWanted int64  `json:"gasWanted"`
+	GasUsed   int64  `json:"gasUsed"`
+	Memo      string `json:"memo" gorm:"type:text"`
+	Code      uint32 `json:"code" gorm:"index"`
+	Log       string `json:"log" gorm:"type:text"`
+
+	Block   Block `json:"block"`
+	BlockId uint  `json:"blockId" gorm:"index"`
 }
 
 type PostProof struct {
 	gorm.Model
 
+	ChainID string `json:"chainId" gorm:"index"`
+
 	Merkle string `json:"merkle" gorm:"index"`
-	Prover string `json:"prover" gorm:"index"`
+	Prover string `json:"prover" gorm:"index"` // the creator of the proof, the provider being proven
+
+	// Signer is the address that submitted the proof. It differs from Prover when the proof was
+	// executed through an authz grant, in which case it is the grantee, or submitted with
+	// MsgPostProofFor, in which case it is the provider's claimer.
+	Signer string `json:"signer" gorm:"index"`
+
+	// Owner and Start identify the file deal being proven along with the merkle.
+	Owner string `json:"owner" gorm:"index"`
+	Start int64  `json:"start"`
+
+	// The proven chunk: ToProve is the chunk index, Item its hex encoded content and HashList the
+	// JSON encoded merkle proof of the chunk.
+	ToProve  int64  `json:"toProve"`
+	Item     string `json:"item" gorm:"type:text"`
+	HashList string `json:"hashList" gorm:"type:text"`
+
+	// Verified is the result of checking the proof against the merkle offline, independently of
+	// the chain. It is nil for proofs indexed before verification existed, VerifyReason holds why
+	// a proof did not verify.
+	Verified     *bool  `json:"verified" gorm:"index"`
+	VerifyReason string `json:"verifyReason"`
+
+	// Code and Log are the DeliverTx result of the transaction carrying the proof.
+	// Proofs from failed transactions (non-zero code) are kept but never count as proven.
+	Code uint32 `json:"code" gorm:"index"`
+	Log  string `json:"log" gorm:"type:text"`
+
+	// Rejected is set when the chain turned the proof down in its MsgPostProofResponse (wrong chunk,
+	// unknown deal, chunk not matching the merkle) without failing the tx. Log then holds the chain's
+	// error message. Rejected proofs never count as proven either.
+	Rejected bool `json:"rejected" gorm:"index"`
+
+	Block   Block `json:"block"`
+	BlockId uint  `json:"blockId" gorm:"index"`
+
+	Transaction   Transaction `json:"transaction"`
+	TransactionId uint        `json:"transactionId" gorm:"index"`
+	MessageIndex  int         `json:"messageIndex"` // position of the message within its tx
+}
+
+// File is a storage deal opened with MsgPostFile. A file is identified on chain by its
+// merkle, owner and start block; the same merkle can be stored by several owners.
+type File struct {
+	gorm.Model
+
+	ChainID string `json:"chainId" gorm:"uniqueIndex:idx_files_chain_deal"`
+	Merkle  string `json:"merkle" gorm:"uniqueIndex:idx_files_chain_deal"`
+	Owner   string `json:"owner" gorm:"uniqueIndex:idx_files_chain_deal;index"`
+	Start   int64  `json:"start" gorm:"uniqueIndex:idx_files_chain_deal"` // block height the file was posted at
+
+	FileSize      int64  `json:"fileSize"`
+	ProofInterval int64  `json:"proofInterval"`
+	ProofType     int64  `json:"proofType"`
+	MaxProofs     int64  `json:"maxProofs"`
+	Expires       int64  `json:"expires"` // block height the file expires at, 0 if it never does
+	Note          string `json:"note" gorm:"type:text"`
+
+	// EndedHeight is the height the file was deleted at, 0 while it is still active.
+	// Expiry is not recorded here, it is derived from Expires when querying.
+	EndedHeight int64      `json:"endedHeight" gorm:"index"`
+	EndedAt     *time.Time `json:"endedAt"`
+
+	Block   Block `json:"block"`
+	BlockId uint  `json:"blockId" gorm:"index"`
+
+	Transaction   Transaction `json:"transaction"`
+	TransactionId uint        `json:"transactionId" gorm:"index"`
+}
+
+// Provider is the current state of a storage provider, built from its lifecycle messages.
+type Provider struct {
+	gorm.Model
+
+	ChainID    string `json:"chainId" gorm:"uniqueIndex:idx_providers_chain_address"`
+	Address    string `json:"address" gorm:"uniqueIndex:idx_providers_chain_address"`
+	IP         string `json:"ip"`
+	Keybase    string `json:"keybase"`
+	TotalSpace int64  `json:"totalSpace"`
+	Active     bool   `json:"active" gorm:"index"`
+
+	InitHeight       int64 `json:"initHeight"`
+	ShutdownHeight   int64 `json:"shutdownHeight"` // 0 unless the provider has shut down
+	LastUpdateHeight int64 `json:"lastUpdateHeight"`
+}
+
+// ProviderEvent is a single change to a provider, holding the values set by the message.
+type ProviderEvent struct {
+	gorm.Model
+
+	ChainID    string `json:"chainId" gorm:"index"`
+	Address    string `json:"address" gorm:"index"`
+	Action     string `json:"action" gorm:"index"` // init, set_ip, set_total_space, set_keybase or shutdown
+	IP         string `json:"ip"`
+	Keybase    string `json:"keybase"`
+	TotalSpace int64  `json:"totalSpace"`
+
+	Block   Block `json:"block"`
+	BlockId uint  `json:"blockId" gorm:"index"`
+
+	Transaction   Transaction `json:"transaction"`
+	TransactionId uint        `json:"transactionId" gorm:"index"`
+}
+
+// StoragePlan is the current storage plan of an owner, from their most recent purchase.
+type StoragePlan struct {
+	gorm.Model
+
+	ChainID      string    `json:"chainId" gorm:"uniqueIndex:idx_storage_plans_chain_owner"`
+	Owner        string    `json:"owner" gorm:"uniqueIndex:idx_storage_plans_chain_owner"`
+	Bytes        int64     `json:"bytes"`
+	DurationDays int64     `json:"durationDays"`
+	PaymentDenom string    `json:"paymentDenom"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end" gorm:"index"`
+
+	LastPurchaseHeight int64 `json:"lastPurchaseHeight"`
+}
+
+// StoragePurchase is a single storage plan purchase, kept as the plan history of an owner.
+type StoragePurchase struct {
+	gorm.Model
+
+	ChainID      string    `json:"chainId" gorm:"index"`
+	Owner        string    `json:"owner" gorm:"index"` // the address the storage was bought for
+	Purchaser    string    `json:"purchaser" gorm:"index"`
+	Kind         string    `json:"kind"` // buy, or upgrade when it replaced a plan that had not ended yet
+	Bytes        int64     `json:"bytes"`
+	DurationDays int64     `json:"durationDays"`
+	PaymentDenom string    `json:"paymentDenom"`
+	Referral     string    `json:"referral"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+
+	Block   Block `json:"block"`
+	BlockId uint  `json:"blockId" gorm:"index"`
+
+	Transaction   Transaction `json:"transaction"`
+	TransactionId uint        `json:"transactionId" gorm:"index"`
+}
+
+// Dispute is an attestation or report form raised over a prover's deal for a file.
+// Attestation forms are requested by a prover to vouch for a deal it could not prove in time,
+// report forms are requested against a prover suspected of losing the data.
+type Dispute struct {
+	gorm.Model
+
+	ChainID string `json:"chainId" gorm:"uniqueIndex:idx_disputes_chain_form"`
+	Kind    string `json:"kind" gorm:"uniqueIndex:idx_disputes_chain_form"` // attestation or report
+	Prover  string `json:"prover" gorm:"uniqueIndex:idx_disputes_chain_form;index"`
+	Merkle  string `json:"merkle" gorm:"uniqueIndex:idx_disputes_chain_form;index"`
+	Owner   string `json:"owner" gorm:"uniqueIndex:idx_disputes_chain_form"`
+	Start   int64  `json:"start" gorm:"uniqueIndex:idx_disputes_chain_form"`
+
+	// a form for the same deal can be raised again once the previous one is resolved
+	OpenedHeight int64     `json:"openedHeight" gorm:"uniqueIndex:idx_disputes_chain_form"`
+	OpenedAt     time.Time `json:"openedAt" gorm:"index"`
+	Requester    string    `json:"requester"`
+	Attesters    string    `json:"attesters"` // comma separated providers selected to attest
+
+	Attestations int `json:"attestations"` // attesters that have completed the form so far
+
+	// Status is open until enough attesters complete the form. A passed attestation form is
+	// "attested" (the prover keeps the deal), a passed report form is "reported" (the prover
+	// is removed from the deal).
+	Status         string     `json:"status" gorm:"index"`
+	ResolvedHeight int64      `json:"resolvedHeight"`
+	ResolvedAt     *time.Time `json:"resolvedAt"`
+}
+
+// DisputeEvent is a single attestation or report message, linked to the dispute it belongs to.
+type DisputeEvent struct {
+	gorm.Model
+
+	ChainID   string `json:"chainId" gorm:"index"`
+	DisputeId uint   `json:"disputeId" gorm:"index"` // 0 when no open form matched the message
+	Action    string `json:"action"`                 // request_attestation, attest, request_report or report
+	Creator   string `json:"creator" gorm:"index"`
+	Prover    string `json:"prover" gorm:"index"`
+	Merkle    string `json:"merkle" gorm:"index"`
+	Owner     string `json:"owner"`
+	Start     int64  `json:"start"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error" gorm:"type:text"`
+
+	Block   Block `json:"block"`
+	BlockId uint  `json:"blockId" gorm:"index"`
+
+	Transaction   Transaction `json:"transaction"`
+	TransactionId uint        `json:"transactionId" gorm:"index"`
+}
+
+// MessageTypeStat counts the messages of a type URL in a block. Messages carried by a wrapper such as
+// authz MsgExec are counted under their own type URL as well as the wrapper's.
+type MessageTypeStat struct {
+	gorm.Model
+
+	ChainID string `json:"chainId" gorm:"uniqueIndex:idx_message_type_stats_chain_height_type"`
+	Height  int64  `json:"height" gorm:"uniqueIndex:idx_message_type_stats_chain_height_type"`
+	TypeURL string `json:"typeUrl" gorm:"uniqueIndex:idx_message_type_stats_chain_height_type;index"`
+	Count   int64  `json:"count"`
+	Failed  int64  `json:"failed"` // messages of failed transactions, included in Count
 
 	Block   Block `json:"block"`
 	BlockId uint  `json:"blockId" gorm:"index"`
 }
+
+// MessageTypeDailyStat rolls the MessageTypeStat of every block of a UTC day up, updated with each block.
+type MessageTypeDailyStat struct {
+	gorm.Model
+
+	ChainID string    `json:"chainId" gorm:"uniqueIndex:idx_message_type_daily_stats_chain_day_type"`
+	Day     time.Time `json:"day" gorm:"type:date;uniqueIndex:idx_message_type_daily_stats_chain_day_type"`
+	TypeURL string    `json:"typeUrl" gorm:"uniqueIndex:idx_message_type_daily_stats_chain_day_type;index"`
+	Count   int64     `json:"count"`
+	Failed  int64     `json:"failed"`
+}
+
+// SyncChunk is a range of heights worked through by a parallel sync. Next is checkpointed while the
+// chunk is indexed, so an interrupted sync only resumes the unfinished part of each chunk.
+type SyncChunk struct {
+	gorm.Model
+
+	ChainID string `json:"chainId" gorm:"uniqueIndex:idx_sync_chunks_chain_range"`
+	Start   int64  `json:"start" gorm:"uniqueIndex:idx_sync_chunks_chain_range"`
+	End     int64  `json:"end" gorm:"uniqueIndex:idx_sync_chunks_chain_range"` // inclusive
+
+	Next   int64 `json:"next"`   // next height to index
+	Failed int64 `json:"failed"` // heights recorded as failed while indexing the chunk
+	Done   bool  `json:"done" gorm:"index"`
+}
+
+// IndexerCursor is how far a named indexer has committed, written in the same transaction as the
+// blocks it covers. The live indexer resumes from its cursor rather than from the highest indexed block,
+// so a backfill above it or a gap below it does not move where it picks up.
+type IndexerCursor struct {
+	gorm.Model
+
+	ChainID   string `json:"chainId" gorm:"uniqueIndex:idx_indexer_cursors_chain_name"`
+	Name      string `json:"name" gorm:"uniqueIndex:idx_indexer_cursors_chain_name"`
+	Height    int64  `json:"height"`    // last committed height
+	BlockHash string `json:"blockHash"` // hash of the block at Height, empty if it was already indexed
+}
+
+// FailedHeight is a block height that could not be indexed after exhausting its retries.
+// The indexer periodically works through these and removes them once they succeed.
+type FailedHeight struct {
+	gorm.Model
+
+	ChainID   string `json:"chainId" gorm:"uniqueIndex:idx_failed_heights_chain_height"`
+	Height    int64  `json:"height" gorm:"uniqueIndex:idx_failed_heights_chain_height"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"lastError"`
+}